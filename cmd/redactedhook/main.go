@@ -3,14 +3,20 @@ package main
 import (
 	"context"
 	"crypto/rand"
+	"crypto/tls"
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
@@ -26,6 +32,13 @@ var (
 
 const (
 	path             = "/hook"
+	healthPath       = "/healthz"
+	metricsPath      = "/metrics"
+	cacheStatsPath   = "/cache/stats"
+	cachePurgePath   = "/cache/purge"
+	evaluatePath     = "/evaluate"
+	versionPath      = "/version"
+	debugPath        = "/debug/requests"
 	EnvServerAddress = "SERVER_ADDRESS"
 	EnvServerPort    = "SERVER_PORT"
 )
@@ -42,11 +55,37 @@ func generateAPIToken(length int) string {
 	return apiKey
 }
 
+// validates the config and prints every problem found, exiting non-zero if there are any.
+func checkConfig(configPath string) {
+	err := config.CheckConfig(configPath)
+	if err == nil {
+		fmt.Fprintf(os.Stdout, "Configuration is valid: %s\n", configPath)
+		return
+	}
+
+	var validationErrors config.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		validationErrors = config.ValidationErrors{err.Error()}
+	}
+	fmt.Fprintf(os.Stderr, "Configuration is invalid: %s\n", configPath)
+	for _, problem := range validationErrors {
+		fmt.Fprintf(os.Stderr, "  - %s\n", problem)
+	}
+	os.Exit(1)
+}
+
 func flagCommands() (string, bool) {
 	var configPath string
+	var check bool
 	flag.StringVar(&configPath, "config", "config.toml", "Path to the configuration file")
+	flag.BoolVar(&check, "check-config", false, "Validate the configuration file and exit")
 	flag.Parse()
 
+	if check {
+		checkConfig(configPath)
+		return configPath, true
+	}
+
 	if len(flag.Args()) > 0 {
 		switch flag.Arg(0) {
 		case "generate-apitoken":
@@ -68,23 +107,55 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
-func startHTTPServer(address, port string) {
-	server := &http.Server{Addr: address + ":" + port}
+func startHTTPServer(listenAddr string) {
+	server := &http.Server{Addr: listenAddr}
+
+	serverCfg := config.GetConfig().Server
+	useTLS := serverCfg.TLSCert != "" && serverCfg.TLSKey != ""
+	if useTLS {
+		reloader, err := newCertReloader(serverCfg.TLSCert, serverCfg.TLSKey)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load TLS certificate")
+		}
+		server.TLSConfig = &tls.Config{GetCertificate: reloader.GetCertificate, MinVersion: tls.VersionTLS12}
+	}
+
+	listener, err := listen(listenAddr)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Failed to listen on %s", listenAddr)
+	}
 
 	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if useTLS {
+			err = server.ServeTLS(listener, "", "") // the certificate comes from TLSConfig.GetCertificate
+		} else {
+			err = server.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatal().Err(err).Msg("Failed to start server")
 		}
 	}()
 
-	log.Info().Msgf("Starting server on %s", address+":"+port)
-	log.Info().Msgf("Version: %s, Commit: %s, Build Date: %s", version, commit, buildDate)
+	if useTLS {
+		log.Info().Msgf("Starting server on %s (TLS)", listenAddr)
+	} else {
+		log.Info().Msgf("Starting server on %s", listenAddr)
+	}
+	log.Info().Msgf("Version: %s, Commit: %s, Build Date: %s, Go: %s", version, commit, buildDate, runtime.Version())
+	if serverCfg.OnAPIError == "approve" {
+		log.Warn().Msg("FAIL-OPEN is enabled (on_api_error = approve): torrents are approved unchecked while the tracker is unreachable")
+	}
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	<-c
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	sig := <-c
+
+	// stop accepting new connections and give in-flight checks time to finish their API calls
+	gracePeriod := time.Duration(config.GetConfig().Server.ShutdownTimeout) * time.Second
+	log.Info().Msgf("Received %s, waiting up to %s for in-flight requests", sig, gracePeriod)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
@@ -92,6 +163,7 @@ func startHTTPServer(address, port string) {
 	} else {
 		log.Info().Msg("Server gracefully stopped")
 	}
+	api.FlushCache() // after the in-flight requests, so the responses they fetched are kept too
 }
 
 func main() {
@@ -103,6 +175,7 @@ func main() {
 	}
 
 	config.InitConfig(configPath)
+	api.SetBuildInfo(version, commit, buildDate)
 
 	err := config.ValidateConfig()
 	if err != nil {
@@ -111,10 +184,39 @@ func main() {
 		log.Debug().Msg("Configuration is valid.")
 	}
 
-	http.HandleFunc(path, api.WebhookHandler)
+	if config.GetConfig().IndexerKeys.VerifyOnStartup {
+		if err := api.VerifyAPIKeys(context.Background()); err != nil {
+			log.Fatal().Err(err).Msg("Invalid API key")
+		}
+	}
+
+	api.LoadCache()
+	api.StartCachePersister()
+
+	// callers outside allowed_ips are turned away before they count against the ingress rate limits
+	protect := func(handler http.HandlerFunc) http.Handler {
+		return api.RequireAllowedIP(api.LimitIngressRate(handler))
+	}
+	http.Handle(path, protect(api.WebhookHandler))
+	http.HandleFunc(healthPath, api.HealthHandler)
+	http.Handle(metricsPath, promhttp.Handler())
+	http.HandleFunc(versionPath, api.VersionHandler)
+	http.Handle(cacheStatsPath, protect(api.CacheStatsHandler))
+	http.Handle(cachePurgePath, protect(api.CachePurgeHandler))
+	http.Handle(evaluatePath, protect(api.EvaluateHandler))
+	http.Handle(debugPath, protect(api.DebugRequestsHandler))
+	for _, route := range config.RouteNames() {
+		http.Handle("/"+route, protect(api.RouteHandler(route)))
+		log.Info().Msgf("Serving route %s at /%s", route, route)
+	}
 
-	address := getEnv(EnvServerAddress, "127.0.0.1")
-	port := getEnv(EnvServerPort, "42135")
+	// SERVER_ADDRESS and SERVER_PORT are kept for existing docker setups and win over address and port,
+	// listen_addr replaces all of them when set
+	serverCfg := config.GetConfig().Server
+	listenAddr := serverCfg.ListenAddr
+	if listenAddr == "" {
+		listenAddr = net.JoinHostPort(getEnv(EnvServerAddress, serverCfg.Address), getEnv(EnvServerPort, serverCfg.Port))
+	}
 
-	startHTTPServer(address, port)
+	startHTTPServer(listenAddr)
 }
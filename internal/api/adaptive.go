@@ -0,0 +1,90 @@
+package api
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	latencySamples    = 20  // responses the p95 is computed over
+	minLatencySamples = 5   // responses needed before the rate is adjusted
+	adaptiveDecrease  = 0.5 // factor the rate is multiplied by when responses are too slow
+	adaptiveIncrease  = 0.1 // share of the configured rate given back per window once responses are fast again
+)
+
+var (
+	latencyTrackers   = make(map[string]*latencyTracker)
+	latencyTrackersMu sync.Mutex
+)
+
+// latencyTracker keeps the most recent API response times of an indexer and the share of the
+// configured rate limit that is currently allowed, which shrinks while the tracker is slow.
+type latencyTracker struct {
+	mu       sync.Mutex
+	samples  []time.Duration
+	next     int
+	scale    float64
+	adjusted time.Time
+}
+
+// returns the latency tracker for the indexer, creating it on first use.
+func getLatencyTracker(indexer string) *latencyTracker {
+	latencyTrackersMu.Lock()
+	defer latencyTrackersMu.Unlock()
+
+	tracker, ok := latencyTrackers[indexer]
+	if !ok {
+		tracker = &latencyTracker{scale: 1}
+		latencyTrackers[indexer] = tracker
+	}
+	return tracker
+}
+
+// stores the response time of a request, replacing the oldest one once the window is full.
+func (t *latencyTracker) record(latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.samples) < latencySamples {
+		t.samples = append(t.samples, latency)
+		return
+	}
+	t.samples[t.next] = latency
+	t.next = (t.next + 1) % latencySamples
+}
+
+// returns the 95th percentile of the recorded response times. the caller holds t.mu.
+func (t *latencyTracker) p95() time.Duration {
+	sorted := append([]time.Duration(nil), t.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(math.Ceil(0.95*float64(len(sorted))))-1]
+}
+
+// returns the rate limit to use, between minRateLimit and rateLimit. the rate is halved whenever the
+// p95 latency is above target and given back gradually once it is below, at most once per window.
+func (t *latencyTracker) rateLimit(indexer string, rateLimit, minRateLimit int, target time.Duration, now time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.samples) >= minLatencySamples && now.Sub(t.adjusted) >= rateLimitWindow {
+		p95 := t.p95()
+		scale := t.scale
+		if p95 > target {
+			scale *= adaptiveDecrease
+		} else {
+			scale += adaptiveIncrease
+		}
+		scale = math.Max(float64(minRateLimit)/float64(rateLimit), math.Min(scale, 1))
+		if scale != t.scale {
+			log.Debug().Msgf("[%s] p95 API latency is %s (target %s), adjusting rate limit to %.0f%%", indexer, p95.Round(time.Millisecond), target, scale*100)
+			t.scale = scale
+			t.adjusted = now
+		}
+	}
+
+	return int(math.Max(float64(minRateLimit), math.Round(float64(rateLimit)*t.scale)))
+}
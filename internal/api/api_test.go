@@ -1,7 +1,22 @@
 package api
 
 import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/s0up4200/redactedhook/internal/config"
+	"golang.org/x/time/rate"
 )
 
 func TestValidateRequestData(t *testing.T) {
@@ -31,6 +46,11 @@ func TestValidateRequestData(t *testing.T) {
 			wantErr: true,
 			errMsg:  "invalid torrent ID: 1000000000",
 		},
+		{
+			name:    "Decomposed record label",
+			request: RequestData{Indexer: "ops", TorrentID: 123, RecordLabel: "Beyonce\u0301, Parkwood", BlockedRecordLabel: "Beyonce\u0301"},
+			wantErr: false,
+		},
 		{
 			name:    "REDKey too long",
 			request: RequestData{Indexer: "redacted", REDKey: "12345678901234567890212345678901234567890123"},
@@ -61,6 +81,12 @@ func TestValidateRequestData(t *testing.T) {
 			wantErr: true,
 			errMsg:  "recordLabels field should only contain alphanumeric characters, spaces, and safe special characters",
 		},
+		{
+			name:    "Both freeleech modes",
+			request: RequestData{Indexer: "ops", FreeleechOnly: true, SkipFreeleech: true},
+			wantErr: true,
+			errMsg:  "freeleech_only and skip_freeleech cannot both be set",
+		},
 		{
 			name:    "Invalid Mode with Uploaders",
 			request: RequestData{Indexer: "ops", Uploaders: "uploader1", Mode: "invalid_mode"},
@@ -82,3 +108,1067 @@ func TestValidateRequestData(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestDataValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		request   RequestData
+		wantField string
+	}{
+		{name: "Missing indexer", request: RequestData{TorrentID: 1}, wantField: "indexer"},
+		{name: "Unsupported indexer", request: RequestData{Indexer: "btn", TorrentID: 1}, wantField: "indexer"},
+		{name: "Missing torrent ID", request: RequestData{Indexer: "redacted"}, wantField: "torrent_id"},
+		{name: "Negative torrent ID with hash", request: RequestData{Indexer: "ops", TorrentID: -1, TorrentHash: "0123456789abcdef0123456789abcdef01234567"}, wantField: "torrent_id"},
+		{name: "Invalid filter", request: RequestData{Indexer: "ops", TorrentID: 1, Uploaders: "someone", Mode: "greylist"}, wantField: "mode"},
+		{name: "Valid request", request: RequestData{Indexer: "ops", TorrentID: 1}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.request.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != tt.wantField {
+				t.Fatalf("expected validation error for field %q, got %v", tt.wantField, err)
+			}
+		})
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	t.Parallel()
+
+	baseDelay := 200 * time.Millisecond
+	for retry := 1; retry <= 3; retry++ {
+		want := baseDelay << (retry - 1)
+		for i := 0; i < 100; i++ {
+			got := backoffDelay(baseDelay, retry)
+			if got < want/2 || got >= want/2+want {
+				t.Fatalf("backoffDelay(%s, %d) = %s, want within [%s, %s)", baseDelay, retry, got, want/2, want/2+want)
+			}
+		}
+	}
+
+	if got := backoffDelay(0, 1); got != 0 {
+		t.Errorf("backoffDelay(0, 1) = %s, want 0", got)
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	cfg := config.GetConfig()
+	saved := *cfg
+	defer func() { *cfg = saved }()
+	cfg.Indexers = nil
+
+	tests := []struct {
+		name        string
+		maxAttempts int
+		retryDelay  int
+		want        time.Duration
+	}{
+		{"Single attempt", 1, 500, 10 * time.Second},
+		{"Three attempts", 3, 500, 30*time.Second + 750*time.Millisecond + 1500*time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.HTTPClient.MaxAttempts = tt.maxAttempts
+			cfg.HTTPClient.RetryDelay = tt.retryDelay
+			if got := fetchTimeout("redacted"); got != tt.want {
+				t.Errorf("fetchTimeout() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{name: "Seconds", value: "120", want: 2 * time.Minute, wantOk: true},
+		{name: "HTTP-date", value: "Mon, 01 Jan 2024 12:00:30 GMT", want: 30 * time.Second, wantOk: true},
+		{name: "HTTP-date in the past", value: "Mon, 01 Jan 2024 11:59:00 GMT", want: 0, wantOk: true},
+		{name: "Empty", value: "", want: 0, wantOk: false},
+		{name: "Negative seconds", value: "-5", want: 0, wantOk: false},
+		{name: "Garbage", value: "soon", want: 0, wantOk: false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, ok := parseRetryAfter(tt.value, now)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("parseRetryAfter(%q) = %s, %t, want %s, %t", tt.value, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestResponseDataUnmarshalCollage(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(`{"status":"success","response":{"id":"7","name":"Best of 2023 &amp; more","torrentGroupIDList":["12",34],"torrentgroups":[{"id":"12","torrents":[{"torrentid":120}]}]}}`)
+
+	responseData := &ResponseData{action: "collage"}
+	if err := json.Unmarshal(body, responseData); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	collage := responseData.Response.Collage
+	if collage == nil {
+		t.Fatal("expected collage to be decoded")
+	}
+	if collage.ID != 7 || collage.Name != "Best of 2023 &amp; more" {
+		t.Errorf("unexpected collage header: %+v", collage)
+	}
+	if len(collage.TorrentGroupIDList) != 2 || collage.TorrentGroupIDList[0] != 12 || collage.TorrentGroupIDList[1] != 34 {
+		t.Errorf("unexpected group IDs: %v", collage.TorrentGroupIDList)
+	}
+	if len(collage.TorrentGroups) != 1 || collage.TorrentGroups[0].Torrents[0].TorrentID != 120 {
+		t.Errorf("unexpected torrent groups: %+v", collage.TorrentGroups)
+	}
+}
+
+func TestResponseCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+
+	c := newResponseCache()
+	c.set("a", CacheItem{}, 2)
+	c.set("b", CacheItem{}, 2)
+	c.get("a") // "b" is now the least recently used
+	c.set("c", CacheItem{}, 2)
+
+	if _, ok := c.get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.get(key); !ok {
+			t.Errorf("expected %s to be cached", key)
+		}
+	}
+	if got := c.len(); got != 2 {
+		t.Errorf("len() = %d, want 2", got)
+	}
+}
+
+func TestByteSizeUnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value   string
+		want    ByteSize
+		wantErr bool
+	}{
+		{value: "500MB", want: 500 << 20},
+		{value: "500MiB", want: 500 << 20},
+		{value: "1.5GB", want: 3 << 29},
+		{value: "2 GiB", want: 2 << 30},
+		{value: "10 parsecs", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.value, func(t *testing.T) {
+			t.Parallel()
+			var got ByteSize
+			err := got.UnmarshalText([]byte(tt.value))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalText(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("UnmarshalText(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	breaker := &circuitBreaker{indexer: "test"}
+	now := time.Now()
+	threshold, cooldown := 2, 30*time.Second
+
+	for i := 0; i < threshold; i++ {
+		if err := breaker.allow(now, threshold, cooldown); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i+1, err)
+		}
+		breaker.record(true, now, threshold)
+	}
+
+	var circuitOpenErr *CircuitOpenError
+	if err := breaker.allow(now.Add(time.Second), threshold, cooldown); !errors.As(err, &circuitOpenErr) {
+		t.Fatalf("expected open breaker to short-circuit, got %v", err)
+	}
+
+	probeAt := now.Add(cooldown)
+	if err := breaker.allow(probeAt, threshold, cooldown); err != nil {
+		t.Fatalf("expected a probe after the cooldown, got %v", err)
+	}
+	if err := breaker.allow(probeAt, threshold, cooldown); !errors.As(err, &circuitOpenErr) {
+		t.Fatalf("expected a second request during the probe to short-circuit, got %v", err)
+	}
+
+	breaker.record(false, probeAt, threshold)
+	if err := breaker.allow(probeAt, threshold, cooldown); err != nil {
+		t.Fatalf("expected closed breaker after a successful probe, got %v", err)
+	}
+}
+
+func TestRequestIDFromHeader(t *testing.T) {
+	t.Parallel()
+
+	if got := requestIDFromHeader("autobrr-123"); got != "autobrr-123" {
+		t.Errorf("expected incoming request ID to be reused, got %q", got)
+	}
+
+	for _, header := range []string{"", "bad id\nwith newline"} {
+		if got := requestIDFromHeader(header); len(got) != 36 || got == header {
+			t.Errorf("expected a generated UUID for %q, got %q", header, got)
+		}
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(`{"torrent_id": 1, "indexer": "ops"}`)
+	signature := "185d6a0a96050436e2e210c25f65efbcafe2b0d7829e9c1256f644df3cc2ff29"
+
+	tests := []struct {
+		name      string
+		signature string
+		wantErr   bool
+	}{
+		{name: "Valid signature", signature: signature},
+		{name: "Valid signature with prefix", signature: "sha256=" + signature},
+		{name: "Wrong signature", signature: strings.Repeat("0", 64), wantErr: true},
+		{name: "Missing signature", signature: "", wantErr: true},
+		{name: "Not hex", signature: "not-a-signature", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := verifySignature(body, tt.signature, "secret"); (err != nil) != tt.wantErr {
+				t.Errorf("verifySignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReserveRequest(t *testing.T) {
+	t.Parallel()
+
+	limiter := rate.NewLimiter(rate.Every(10*time.Second), 1)
+
+	if _, ok := reserveRequest(limiter); !ok {
+		t.Fatal("expected the first request to be allowed")
+	}
+
+	for i := 0; i < 2; i++ {
+		retryAfter, ok := reserveRequest(limiter)
+		if ok || retryAfter <= 0 || retryAfter > 10*time.Second {
+			t.Fatalf("expected a rejected request with a retry delay, got ok=%t retryAfter=%s", ok, retryAfter)
+		}
+	}
+}
+
+func TestLooksLikeJSON(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{`{"status":"success"}`, true},
+		{"  \n[1,2]", true},
+		{"<!DOCTYPE html><title>Just a moment...</title>", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := looksLikeJSON([]byte(tt.body)); got != tt.want {
+			t.Errorf("looksLikeJSON(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyBearerToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		wantErr       bool
+	}{
+		{"valid token", "Bearer s3cret", false},
+		{"wrong token", "Bearer nope", true},
+		{"missing scheme", "s3cret", true},
+		{"empty header", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifyBearerToken(tt.authorization, "s3cret"); (err != nil) != tt.wantErr {
+				t.Errorf("verifyBearerToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResponseCacheStats(t *testing.T) {
+	c := newResponseCache()
+	now := time.Now()
+	c.set("ops:torrentID 1", CacheItem{Indexer: "ops", LastFetched: now.Add(-time.Minute)}, 10)
+	c.set("ops:torrentID 2", CacheItem{Indexer: "ops", LastFetched: now}, 10)
+	c.set("redacted:torrentID 1", CacheItem{Indexer: "redacted", LastFetched: now}, 10)
+	c.recordLookup("ops", true)
+	c.recordLookup("ops", false)
+	c.recordLookup("redacted", false)
+
+	stats := c.stats()
+	if stats.Entries != 3 || stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("stats() = %d entries, %d hits, %d misses, want 3, 1, 2", stats.Entries, stats.Hits, stats.Misses)
+	}
+	if ops := stats.Indexers["ops"]; ops == nil || ops.Entries != 2 || ops.Hits != 1 || ops.Misses != 1 {
+		t.Errorf("stats().Indexers[ops] = %+v, want 2 entries, 1 hit, 1 miss", ops)
+	}
+	if stats.Oldest == nil || !stats.Oldest.Equal(now.Add(-time.Minute)) || stats.Newest == nil || !stats.Newest.Equal(now) {
+		t.Errorf("stats() oldest/newest = %v/%v", stats.Oldest, stats.Newest)
+	}
+}
+
+func TestResponseCachePurge(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    CachePurgeRequest
+		want       int
+		wantHashID bool // whether the hash resolved to ops torrent 1 is still known
+	}{
+		{"everything", CachePurgeRequest{}, 3, false},
+		{"indexer", CachePurgeRequest{Indexer: "ops"}, 2, false},
+		{"action and ID", CachePurgeRequest{Action: "torrent", ID: 1}, 2, false},
+		{"single entry", CachePurgeRequest{Indexer: "ops", Action: "user", ID: 1}, 1, true},
+		{"no match", CachePurgeRequest{Indexer: "redacted", ID: 2}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newResponseCache()
+			c.set("ops:torrentID 1", CacheItem{Indexer: "ops", Action: "torrent", ID: 1}, 10)
+			c.set("ops:userID 1", CacheItem{Indexer: "ops", Action: "user", ID: 1}, 10)
+			c.set("redacted:torrentID 1", CacheItem{Indexer: "redacted", Action: "torrent", ID: 1}, 10)
+			hashKey := torrentHashCacheKey("ops", "key", "abc")
+			rememberHash(hashKey, 1)
+			defer func() { hashIDs.Lock(); delete(hashIDs.ids, hashKey); hashIDs.Unlock() }()
+
+			if got := c.purge(tt.request.matches); got != tt.want {
+				t.Errorf("purge() = %d, want %d", got, tt.want)
+			}
+			if c.len() != 3-tt.want {
+				t.Errorf("len() = %d after purge, want %d", c.len(), 3-tt.want)
+			}
+			if _, ok := resolveHash(hashKey); ok != tt.wantHashID {
+				t.Errorf("resolveHash() ok = %t after purge, want %t", ok, tt.wantHashID)
+			}
+		})
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	tests := map[string]string{
+		"hip.hop":        "hip.hop",
+		"Hip Hop":        "hip.hop",
+		" drum_and bass": "drum.and.bass",
+		"Electronic":     "electronic",
+	}
+
+	for tag, want := range tests {
+		if got := normalizeTag(tag); got != want {
+			t.Errorf("normalizeTag(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestDecodeFormPayload(t *testing.T) {
+	body := "indexer=ops&torrent_id=123&minsize=10MB&max_age=24h&minratio=0.8&scene_only=true&require_artist=Aphex+Twin,Squarepusher&group_ids_block=1&group_ids_block=2&unknown=x"
+	r := httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	var requestData RequestData
+	if err := decodePayload(r, &requestData); err != nil {
+		t.Fatalf("decodePayload() error = %v", err)
+	}
+	if requestData.Indexer != "ops" || requestData.TorrentID != 123 || requestData.MinSize.String() != "10.00MB" || requestData.MaxAge != Duration(24*time.Hour) ||
+		requestData.MinRatio != 0.8 || !requestData.SceneOnly || strings.Join(requestData.RequireArtist, "|") != "Aphex Twin|Squarepusher" || fmt.Sprint(requestData.GroupIDsBlock) != "[1 2]" {
+		t.Errorf("decodePayload() = %+v", requestData)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader("indexer=ops&torrent_id=abc"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := decodePayload(r, &RequestData{}); err == nil {
+		t.Error("decodePayload() with an invalid torrent_id succeeded")
+	}
+}
+
+func TestFoldString(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"Beyonc\u00e9", "Beyonce\u0301"},
+		{"BEYONC\u00c9", "beyonc\u00e9"},
+		{"Stra\u00dfe", "STRASSE"},
+		{"\u039c\u03a0\u039f\u03a5\u039a\u039f\u03a5\u039a\u0399", "\u03bc\u03c0\u03bf\u03c5\u03ba\u03bf\u03c5\u03ba\u03b9"},
+	}
+
+	for _, tt := range tests {
+		if foldString(tt.a) != foldString(tt.b) {
+			t.Errorf("foldString(%q) = %q, want it to match foldString(%q) = %q", tt.a, foldString(tt.a), tt.b, foldString(tt.b))
+		}
+	}
+}
+
+func TestTrackerTimeUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data string
+		want time.Time
+	}{
+		{`"2024-03-01 12:30:00"`, time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)},
+		{`"2024-03-01T12:30:00+01:00"`, time.Date(2024, 3, 1, 11, 30, 0, 0, time.UTC)},
+		{`1709296200`, time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)},
+		{`"0000-00-00 00:00:00"`, time.Time{}},
+		{`null`, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		var got trackerTime
+		if err := json.Unmarshal([]byte(tt.data), &got); err != nil {
+			t.Errorf("Unmarshal(%s) error = %v", tt.data, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("Unmarshal(%s) = %v, want %v", tt.data, got.Time, tt.want)
+		}
+	}
+}
+
+func TestRedactResponse(t *testing.T) {
+	body := []byte(`{"status":"success","response":{"username":"bob","authkey":"a1","passkey":"p1","notes":["secret-key"]}}`)
+
+	got := redactResponse(body, "secret-key")
+	for _, secret := range []string{"a1", "p1", "secret-key"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("redactResponse() leaks %q:\n%s", secret, got)
+		}
+	}
+	if !strings.Contains(got, `"username": "bob"`) {
+		t.Errorf("redactResponse() dropped unrelated fields:\n%s", got)
+	}
+}
+
+func TestApplyRuleGroup(t *testing.T) {
+	base := &RequestData{Indexer: "ops", TorrentID: 1, Formats: "FLAC", MinSeeders: 5}
+
+	tests := []struct {
+		name      string
+		ruleGroup map[string]interface{}
+		want      RequestData
+		wantErr   bool
+	}{
+		{
+			name:      "overrides and keeps fields",
+			ruleGroup: map[string]interface{}{"formats": "MP3", "encodings": "V0 (VBR)", "media": "WEB"},
+			want:      RequestData{Indexer: "ops", TorrentID: 1, Formats: "MP3", Encodings: "V0 (VBR)", Media: "WEB", MinSeeders: 5},
+		},
+		{
+			name:      "typed values",
+			ruleGroup: map[string]interface{}{"min_seeders": int64(10), "minsize": "100MB"},
+			want:      RequestData{Indexer: "ops", TorrentID: 1, Formats: "FLAC", MinSeeders: 10, MinSize: ByteSize(100 * 1024 * 1024)},
+		},
+		{name: "wrong type", ruleGroup: map[string]interface{}{"formats": []string{"FLAC"}}, wantErr: true},
+		{name: "invalid filters", ruleGroup: map[string]interface{}{"scene_only": true, "skip_scene": true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyRuleGroup(base, tt.ruleGroup)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyRuleGroup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Formats != tt.want.Formats || got.Encodings != tt.want.Encodings || got.Media != tt.want.Media ||
+				got.MinSeeders != tt.want.MinSeeders || got.MinSize != tt.want.MinSize) {
+				t.Errorf("applyRuleGroup() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+	if base.Formats != "FLAC" {
+		t.Errorf("applyRuleGroup() modified the original request: %+v", *base)
+	}
+}
+
+func TestRatioBufferAfter(t *testing.T) {
+	const gib = 1 << 30
+	tests := []struct {
+		name                       string
+		uploaded, downloaded, size int64
+		requiredRatio              float64
+		want                       int64
+	}{
+		{"plenty of buffer", 60 * gib, 50 * gib, 1 * gib, 0.6, 49 * gib},
+		{"exactly at the limit", 6 * gib, 9 * gib, 1 * gib, 0.6, 0},
+		{"underwater", 6 * gib, 10 * gib, 1 * gib, 0.6, -1 * gib},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ratioBufferAfter(tt.uploaded, tt.downloaded, tt.size, tt.requiredRatio); got != tt.want {
+				t.Errorf("ratioBufferAfter() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJitterTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		percent int
+		random  float64
+		want    time.Duration
+	}{
+		{"disabled", 0, 0.9, 100 * time.Second},
+		{"lowest", 10, 0, 90 * time.Second},
+		{"middle", 10, 0.5, 100 * time.Second},
+		{"close to highest", 10, 0.99, 109800 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jitterTTL(100*time.Second, tt.percent, tt.random); got != tt.want {
+				t.Errorf("jitterTTL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseCacheKey(t *testing.T) {
+	key := responseCacheKey("redacted", "secret-key-1", "torrent", 1)
+	if strings.Contains(key, "secret-key-1") {
+		t.Errorf("responseCacheKey() = %q, exposes the API key", key)
+	}
+	if other := responseCacheKey("redacted", "secret-key-2", "torrent", 1); other == key {
+		t.Errorf("responseCacheKey() = %q for different API keys", key)
+	}
+	if same := responseCacheKey("redacted", "secret-key-1", "torrent", 1); same != key {
+		t.Errorf("responseCacheKey() = %q, want %q for the same API key", same, key)
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	body := []byte(`{"status":"success"}`)
+
+	var gzipped, zlibbed, deflated bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipped)
+	gzipWriter.Write(body)
+	gzipWriter.Close()
+	zlibWriter := zlib.NewWriter(&zlibbed)
+	zlibWriter.Write(body)
+	zlibWriter.Close()
+	flateWriter, _ := flate.NewWriter(&deflated, flate.DefaultCompression)
+	flateWriter.Write(body)
+	flateWriter.Close()
+
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding string
+		wantErr  bool
+	}{
+		{"identity", body, "", false},
+		{"gzip", gzipped.Bytes(), "gzip", false},
+		{"zlib deflate", zlibbed.Bytes(), "deflate", false},
+		{"raw deflate", deflated.Bytes(), "deflate", false},
+		{"corrupt gzip", body, "gzip", true},
+		{"unsupported", body, "br", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeBody(tt.data, tt.encoding, 1024)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeBody() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, body) {
+				t.Errorf("decodeBody() = %q, want %q", got, body)
+			}
+		})
+	}
+}
+
+func TestIsKeyRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"api error", &APIError{Indexer: "ops", Message: "bad credentials"}, true},
+		{"unauthorized", &HTTPStatusError{Indexer: "ops", StatusCode: http.StatusUnauthorized}, true},
+		{"server error", &HTTPStatusError{Indexer: "ops", StatusCode: http.StatusBadGateway}, false},
+		{"timeout", &TimeoutError{Indexer: "ops", Timeout: time.Second}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isKeyRejected(tt.err); got != tt.want {
+				t.Errorf("isKeyRejected() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorSentinels(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{"timeout", &TimeoutError{Indexer: "redacted", Timeout: time.Second}, ErrTimeout, true},
+		{"wrapped rate limit", fmt.Errorf("fetching: %w", &RateLimitError{Indexer: "redacted"}), ErrRateLimited, true},
+		{"forbidden", &HTTPStatusError{StatusCode: http.StatusForbidden}, ErrUnauthorized, true},
+		{"server error", &HTTPStatusError{StatusCode: http.StatusServiceUnavailable}, ErrTrackerUnavailable, true},
+		{"server error is not unauthorized", &HTTPStatusError{StatusCode: http.StatusServiceUnavailable}, ErrUnauthorized, false},
+		{"bad id", &APIError{Indexer: "redacted", Message: "bad id parameter"}, ErrNotFound, true},
+		{"other api error", &APIError{Indexer: "redacted", Message: "bad credentials"}, ErrNotFound, false},
+		{"unreachable", &UnreachableError{Err: errors.New("connection refused")}, ErrTrackerUnavailable, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Errorf("errors.Is(%v, %v) = %t, want %t", tt.err, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatencyTrackerRateLimit(t *testing.T) {
+	tracker := &latencyTracker{scale: 1}
+	now := time.Now()
+	target := 500 * time.Millisecond
+
+	steps := []struct {
+		name    string
+		latency time.Duration
+		want    int
+	}{
+		{"slow", time.Second, 5},
+		{"still slow", time.Second, 3},
+		{"not below the minimum", time.Second, 2},
+		{"at the minimum", time.Second, 2},
+		{"recovering", 100 * time.Millisecond, 3},
+	}
+
+	for i, step := range steps {
+		tracker.samples = nil
+		for j := 0; j < latencySamples; j++ {
+			tracker.record(step.latency)
+		}
+		if got := tracker.rateLimit("ops", 10, 2, target, now.Add(time.Duration(i+1)*rateLimitWindow)); got != step.want {
+			t.Errorf("%s: rateLimit() = %d, want %d", step.name, got, step.want)
+		}
+	}
+}
+
+func TestSetServerTiming(t *testing.T) {
+	ctx := withServerTiming(context.Background())
+	addTiming(ctx, "cache", time.Now())
+
+	header := make(http.Header)
+	setServerTiming(ctx, header)
+	got := header.Get("Server-Timing")
+	if !strings.HasPrefix(got, "cache;dur=") || !strings.Contains(got, ", eval;dur=") || strings.Contains(got, "fetch") {
+		t.Errorf("Server-Timing = %q, want cache and eval only", got)
+	}
+}
+
+func TestMusicInfoCredits(t *testing.T) {
+	var musicInfo MusicInfo
+	data := `{"artists":[{"id":1,"name":"Main"}],"with":[{"id":2,"name":"Guest"}],"composers":[{"id":3,"name":"Composer"}],"remixedBy":[{"id":4,"name":"Remixer"}]}`
+	if err := json.Unmarshal([]byte(data), &musicInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"main":     "Main",
+		"guest":    "Guest",
+		"composer": "Composer",
+		"remixer":  "Remixer",
+		"dj":       "",
+	}
+
+	for role, want := range tests {
+		var got string
+		if credits := musicInfo.credits(role); len(credits) > 0 {
+			got = credits[0].Name
+		}
+		if got != want {
+			t.Errorf("credits(%q) = %q, want %q", role, got, want)
+		}
+	}
+}
+
+func TestParseAudioQuality(t *testing.T) {
+	tests := []struct {
+		name           string
+		encoding       string
+		media          string
+		releaseName    string
+		wantBitDepth   int
+		wantSampleRate float64
+	}{
+		{"hi-res with kHz", "24bit Lossless", "WEB", "Artist - Album (2020) [FLAC 24bit 96kHz]", 24, 96},
+		{"hi-res with pair", "24bit Lossless", "WEB", "Artist - Album (2020) [24-192]", 24, 192},
+		{"hi-res without sample rate", "24bit Lossless", "WEB", "Artist - Album (2020) [FLAC]", 24, 0},
+		{"cd rip", "Lossless", "CD", "Artist - Album (1999) [FLAC]", 16, 44.1},
+		{"lossy", "320", "WEB", "Artist - Album (2020) [MP3 320]", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseBitDepth(tt.encoding); got != tt.wantBitDepth {
+				t.Errorf("parseBitDepth() = %d, want %d", got, tt.wantBitDepth)
+			}
+			if got := parseSampleRate(tt.media, tt.releaseName); got != tt.wantSampleRate {
+				t.Errorf("parseSampleRate() = %v, want %v", got, tt.wantSampleRate)
+			}
+		})
+	}
+}
+
+func TestIngressLimiters(t *testing.T) {
+	l := &ingressLimiters{clients: make(map[string]*ingressClient)}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if delay := l.wait("10.0.0.1", 0, 2, now); delay != 0 {
+			t.Fatalf("wait() request %d = %s, want 0", i+1, delay)
+		}
+	}
+	if delay := l.wait("10.0.0.1", 0, 2, now); delay != 30*time.Second {
+		t.Errorf("wait() over the client limit = %s, want 30s", delay)
+	}
+	if delay := l.wait("10.0.0.2", 0, 2, now); delay != 0 {
+		t.Errorf("wait() for another client = %s, want 0", delay)
+	}
+	if delay := l.wait("10.0.0.1", 0, 2, now.Add(30*time.Second)); delay != 0 {
+		t.Errorf("wait() after the refill = %s, want 0", delay)
+	}
+
+	if delay := l.wait("10.0.0.3", 1, 0, now); delay != 0 {
+		t.Errorf("wait() within the global limit = %s, want 0", delay)
+	}
+	if delay := l.wait("10.0.0.4", 1, 0, now); delay != time.Minute {
+		t.Errorf("wait() over the global limit = %s, want 1m", delay)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := config.ParseNetworks([]string{"10.0.0.1", "172.16.0.0/12"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		want         string
+	}{
+		{"direct", "192.168.1.5:5555", "", "192.168.1.5"},
+		{"untrusted peer sending the header", "192.168.1.5:5555", "127.0.0.1", "192.168.1.5"},
+		{"trusted proxy", "10.0.0.1:5555", "203.0.113.7", "203.0.113.7"},
+		{"chain of trusted proxies", "10.0.0.1:5555", "1.2.3.4, 203.0.113.7, 172.17.0.2", "203.0.113.7"},
+		{"unix socket", "@", "203.0.113.7", "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/hook", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if got := clientIP(r, trusted); got.String() != tt.want {
+				t.Errorf("clientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileList string
+		want     int
+	}{
+		{"empty", "", 0},
+		{"single file", "01 - Intro.flac{{{1234}}}", 1},
+		{"several files", "01 - Intro.flac{{{1234}}}|||02 - Outro.flac{{{5678}}}|||cover.jpg{{{90}}}", 3},
+		{"trailing separator", "01 - Intro.flac{{{1234}}}|||", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countFiles(tt.fileList); got != tt.want {
+				t.Errorf("countFiles() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQualityRank(t *testing.T) {
+	preference := parseQualityPreference("FLAC 24bit Lossless, FLAC Lossless,MP3")
+
+	tests := []struct {
+		name     string
+		format   string
+		encoding string
+		want     int
+	}{
+		{"exact entry", "FLAC", "Lossless", 1},
+		{"case insensitive", "flac", "24BIT LOSSLESS", 0},
+		{"bare format", "MP3", "V0 (VBR)", 2},
+		{"not listed", "AAC", "256", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := qualityRank(preference, tt.format, tt.encoding); got != tt.want {
+				t.Errorf("qualityRank() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodingRank(t *testing.T) {
+	preference := parseQualityPreference("Lossless,24bit Lossless")
+
+	tests := map[string]int{
+		"Lossless":       0,
+		"24BIT LOSSLESS": 1,
+		"320":            2,
+	}
+
+	for encoding, want := range tests {
+		if got := encodingRank(preference, encoding); got != want {
+			t.Errorf("encodingRank(%q) = %d, want %d", encoding, got, want)
+		}
+	}
+}
+
+func TestRejectStatusWriter(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	writeVerdict(&rejectStatusWriter{ResponseWriter: recorder, status: 420}, StatusSizeNotAllowed, "too small")
+
+	if recorder.Code != 420 {
+		t.Errorf("status = %d, want 420", recorder.Code)
+	}
+	if !strings.Contains(recorder.Body.String(), `"reason":"too small"`) {
+		t.Errorf("body = %s, want the reason", recorder.Body.String())
+	}
+}
+
+func TestDebugHistory(t *testing.T) {
+	history := &debugHistory{}
+	for _, id := range []string{"a", "b", "c"} {
+		history.add(debugEntry{RequestID: id}, 2)
+	}
+
+	tests := []struct {
+		size int
+		want []string
+	}{
+		{2, []string{"c", "b"}},
+		{1, []string{"c"}},
+		{0, []string{}},
+	}
+
+	for _, tt := range tests {
+		ids := []string{}
+		for _, entry := range history.list(tt.size) {
+			ids = append(ids, entry.RequestID)
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("list(%d) = %v, want %v", tt.size, ids, tt.want)
+		}
+	}
+}
+
+func TestRememberHashEvictsOne(t *testing.T) {
+	cfg := config.GetConfig()
+	saved := *cfg
+	defer func() { *cfg = saved }()
+	cfg.Cache.MaxEntries = 2
+
+	hashIDs.Lock()
+	savedIDs := hashIDs.ids
+	hashIDs.ids = make(map[string]int)
+	hashIDs.Unlock()
+	defer func() {
+		hashIDs.Lock()
+		hashIDs.ids = savedIDs
+		hashIDs.Unlock()
+	}()
+
+	rememberHash("a", 1)
+	rememberHash("b", 2)
+	rememberHash("b", 3)
+	if got := len(hashIDs.ids); got != 2 {
+		t.Fatalf("known hashes after updating one = %d, want 2", got)
+	}
+	rememberHash("c", 4)
+	if got := len(hashIDs.ids); got != 2 {
+		t.Errorf("known hashes past max_entries = %d, want 2", got)
+	}
+	if id, ok := resolveHash("c"); !ok || id != 4 {
+		t.Errorf("resolveHash(c) = %d, %v, want 4, true", id, ok)
+	}
+}
+
+func TestHealthDeepRequiresToken(t *testing.T) {
+	cfg := config.GetConfig()
+	saved := *cfg
+	defer func() { *cfg = saved }()
+	cfg.Authorization.APIToken = "token"
+	cfg.Authorization.WebhookToken = ""
+	cfg.IndexerKeys = config.IndexerKeys{}
+	cfg.Indexers = nil
+
+	tests := []struct {
+		name       string
+		target     string
+		token      string
+		wantStatus int
+	}{
+		{"Shallow without token", "/healthz", "", http.StatusOK},
+		{"Deep without token", "/healthz?deep=true", "", http.StatusUnauthorized},
+		{"Deep with wrong token", "/healthz?deep=true", "wrong", http.StatusUnauthorized},
+		{"Deep with token", "/healthz?deep=true", "token", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.token != "" {
+				req.Header.Set("X-API-Token", tt.token)
+			}
+			rec := httptest.NewRecorder()
+			HealthHandler(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("HealthHandler(%s) status = %d, want %d", tt.target, rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestDebugHistorySkipsUnauthorized(t *testing.T) {
+	cfg := config.GetConfig()
+	saved := *cfg
+	defer func() { *cfg = saved }()
+	cfg.Authorization.APIToken = "token"
+	cfg.Server.DebugHistory = 5
+
+	before := len(debugRequests.list(5))
+	WebhookHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader(`{}`)))
+	if got := len(debugRequests.list(5)); got != before {
+		t.Errorf("debug history has %d entries after an unauthorized request, want %d", got, before)
+	}
+}
+
+func TestDebugHistoryGrowAfterWrap(t *testing.T) {
+	history := &debugHistory{}
+	for _, id := range []string{"1", "2", "3", "4"} {
+		history.add(debugEntry{RequestID: id}, 3)
+	}
+	history.add(debugEntry{RequestID: "5"}, 5)
+
+	ids := []string{}
+	for _, entry := range history.list(5) {
+		ids = append(ids, entry.RequestID)
+	}
+	if got := strings.Join(ids, ","); got != "5,4,3,2" {
+		t.Errorf("list(5) = %s, want 5,4,3,2", got)
+	}
+}
+
+func TestScoreTorrent(t *testing.T) {
+	var torrentData ResponseData
+	data := `{"response":{"group":{"tags":["hip.hop"]},"torrent":{"format":"FLAC","media":"WEB","seeders":250,"scene":true}}}`
+	if err := json.Unmarshal([]byte(data), &torrentData); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rule config.ScoreRule
+		want int
+	}{
+		{config.ScoreRule{Field: "format", Value: "flac", Points: 10}, 10},
+		{config.ScoreRule{Field: "media", Value: "CD", Points: 5}, 0},
+		{config.ScoreRule{Field: "tag", Value: "Hip Hop", Points: 2}, 2},
+		{config.ScoreRule{Field: "scene", Points: -20}, -20},
+		{config.ScoreRule{Field: "trumpable", Points: -20}, 0},
+		{config.ScoreRule{Field: "seeders", Per: 100, Points: 3}, 6},
+	}
+
+	for _, tt := range tests {
+		if got, _ := scoreTorrent([]config.ScoreRule{tt.rule}, "ops", &torrentData); got != tt.want {
+			t.Errorf("scoreTorrent(%+v) = %d, want %d", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestRouteFiltersKeepConfig(t *testing.T) {
+	cfg := config.GetConfig()
+	saved := *cfg
+	defer func() { *cfg = saved }()
+
+	cfg.Authorization.APIToken = "token"
+	cfg.Artists.RequireArtist = []string{"Global Artist"}
+	cfg.GroupIDs.Block = []int{111}
+	cfg.Routes = map[string]map[string]interface{}{
+		"route": {"require_artist": []interface{}{"Route Artist"}, "group_ids_block": []interface{}{999}},
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/route", strings.NewReader(`{}`))
+	request.Header.Set("X-API-Token", "token")
+	RouteHandler("route")(httptest.NewRecorder(), request)
+
+	if got := cfg.Artists.RequireArtist; len(got) != 1 || got[0] != "Global Artist" {
+		t.Errorf("require_artist = %v, want [Global Artist]", got)
+	}
+	if got := cfg.GroupIDs.Block; len(got) != 1 || got[0] != 111 {
+		t.Errorf("group_ids_block = %v, want [111]", got)
+	}
+
+	// unauthorized callers can't tell configured routes from missing ones
+	recorder := httptest.NewRecorder()
+	RouteHandler("missing")(recorder, httptest.NewRequest(http.MethodPost, "/missing", strings.NewReader(`{}`)))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d for an unauthorized request to a missing route, want 401", recorder.Code)
+	}
+}
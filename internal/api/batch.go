@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	maxBatchSize = 100 // torrent IDs accepted in a single request
+	batchWorkers = 4   // torrent IDs evaluated at the same time
+)
+
+// batchResponse is the JSON body sent back for a request with torrent_ids, keyed by torrent ID.
+type batchResponse struct {
+	Results map[string]verdict `json:"results"`
+}
+
+// evaluates every torrent ID in the batch concurrently, bounded by batchWorkers, and responds with a verdict per ID.
+// the rate limiter and the cache are shared with single requests, so IDs that were checked before are not fetched again.
+func evaluateBatch(ctx context.Context, w http.ResponseWriter, requestData *RequestData, apiBase string, dryRun bool) {
+	response := batchResponse{Results: make(map[string]verdict, len(requestData.TorrentIDs))}
+	var mu sync.Mutex
+
+	var g errgroup.Group
+	g.SetLimit(batchWorkers)
+	for _, torrentID := range requestData.TorrentIDs {
+		torrentID := torrentID
+		g.Go(func() error {
+			torrentRequest := *requestData
+			torrentRequest.TorrentID = torrentID
+
+			torrentCtx := withScore(ctx)
+			result := verdict{Approved: true}
+			if statusCode, err := evaluateRuleGroups(torrentCtx, &torrentRequest, apiBase); err != nil {
+				if failOpen(err) {
+					log.Ctx(ctx).Warn().Str("indexer", requestData.Indexer).Int("torrent_id", torrentID).Str("reason", err.Error()).Msg("FAIL-OPEN: tracker unreachable, approving without checks (on_api_error = approve)")
+				} else if dryRun {
+					log.Ctx(ctx).Info().Str("indexer", requestData.Indexer).Int("torrent_id", torrentID).Int("status", statusCode).Str("reason", err.Error()).Msg("Dry run simulation: would reject")
+				} else {
+					result = verdict{Approved: false, Reason: err.Error()}
+				}
+			}
+			result.Score = scoreFrom(torrentCtx)
+
+			mu.Lock()
+			response.Results[strconv.Itoa(torrentID)] = result
+			mu.Unlock()
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	setServerTiming(ctx, w.Header())
+
+	log.Ctx(ctx).Info().Str("indexer", requestData.Indexer).Ints("torrent_ids", requestData.TorrentIDs).Msg("Evaluated batch")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to encode response")
+	}
+}
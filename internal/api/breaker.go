@@ -0,0 +1,121 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/redactedhook/internal/config"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker short-circuits requests to an indexer after repeated failures, so a dead API
+// is not hammered by every webhook. after the cooldown a single probe request is let through,
+// and its outcome decides whether the breaker closes again or stays open.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	indexer  string
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+var (
+	breakers   = make(map[string]*circuitBreaker)
+	breakersMu sync.Mutex
+)
+
+// returns the circuit breaker for the indexer, creating it on first use.
+func getBreaker(indexer string) *circuitBreaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+
+	breaker, ok := breakers[indexer]
+	if !ok {
+		breaker = &circuitBreaker{indexer: indexer}
+		breakers[indexer] = breaker
+	}
+	return breaker
+}
+
+// returns the configured failure threshold and cooldown for the indexer's breaker.
+func getBreakerPolicy(indexer string) (int, time.Duration) {
+	indexerCfg := config.GetConfig().Indexers[indexer]
+	return indexerCfg.BreakerThreshold, time.Duration(indexerCfg.BreakerCooldown) * time.Second
+}
+
+// checks if a request may be sent, returning a *CircuitOpenError while the breaker is open.
+func (b *circuitBreaker) allow(now time.Time, threshold int, cooldown time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if threshold <= 0 {
+		return nil
+	}
+
+	switch b.state {
+	case breakerOpen:
+		if retryIn := b.openedAt.Add(cooldown).Sub(now); retryIn > 0 {
+			return &CircuitOpenError{Indexer: b.indexer, RetryIn: retryIn}
+		}
+		log.Info().Msgf("[%s] Circuit breaker half-open, probing the API", b.indexer)
+		b.setState(breakerHalfOpen)
+		b.probing = true
+		return nil
+	case breakerHalfOpen:
+		if b.probing {
+			return &CircuitOpenError{Indexer: b.indexer, RetryIn: cooldown}
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// records the outcome of a request that was let through by allow.
+func (b *circuitBreaker) record(failed bool, now time.Time, threshold int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		if b.state != breakerClosed {
+			log.Info().Msgf("[%s] Circuit breaker closed, API is responding again", b.indexer)
+		}
+		b.failures = 0
+		b.setState(breakerClosed)
+		return
+	}
+
+	b.failures++
+	if threshold > 0 && (b.state == breakerHalfOpen || b.failures >= threshold) {
+		if b.state != breakerOpen {
+			log.Warn().Msgf("[%s] Circuit breaker opened after %d consecutive failures", b.indexer, b.failures)
+		}
+		b.openedAt = now
+		b.setState(breakerOpen)
+	}
+}
+
+// releases a half-open probe slot when allow let a request through that was never sent.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *circuitBreaker) setState(state breakerState) {
+	b.state = state
+	open := 0.0
+	if state == breakerOpen {
+		open = 1
+	}
+	circuitBreakerOpen.WithLabelValues(b.indexer).Set(open)
+}
@@ -1,27 +1,353 @@
 package api
 
 import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/redactedhook/internal/config"
 )
 
-var cache = make(map[string]CacheItem) // keyed by indexer
+const defaultCacheMaxEntries = 1000
 
-// stores the responseData in cache with the specified cacheKey and updates the LastFetched timestamp.
-func cacheResponseData(cacheKey string, responseData *ResponseData) {
-	cache[cacheKey] = CacheItem{
-		Data:        responseData,
-		LastFetched: time.Now(),
+var cache = newResponseCache()
+
+// responseCache is a concurrency-safe LRU of API responses keyed by action and ID.
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used at the front
+	lookups map[string]*cacheLookups
+}
+
+// cacheLookups counts cache hits and misses for one indexer since startup.
+type cacheLookups struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+type cacheEntry struct {
+	key  string
+	item CacheItem
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		lookups: make(map[string]*cacheLookups),
+	}
+}
+
+func (c *responseCache) get(key string) (CacheItem, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return CacheItem{}, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).item, true
+}
+
+// stores the item and evicts least recently used entries until the cache holds at most maxEntries.
+func (c *responseCache) set(key string, item CacheItem, maxEntries int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*cacheEntry).item = item
+		c.order.MoveToFront(element)
+	} else {
+		c.entries[key] = c.order.PushFront(&cacheEntry{key: key, item: item})
+	}
+
+	for c.order.Len() > maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *responseCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+}
+
+// removes every entry for which match returns true and returns how many were removed. the torrent IDs
+// hashes resolved to are forgotten along with the torrents they point to.
+func (c *responseCache) purge(match func(CacheItem) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hashIDs.Lock()
+	for hashKey, id := range hashIDs.ids {
+		indexer, _, _ := strings.Cut(hashKey, ":")
+		if match(CacheItem{Indexer: indexer, Action: "torrent", ID: id}) {
+			delete(hashIDs.ids, hashKey)
+		}
+	}
+	hashIDs.Unlock()
+
+	purged := 0
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		entry := element.Value.(*cacheEntry)
+		if match(entry.item) {
+			c.order.Remove(element)
+			delete(c.entries, entry.key)
+			purged++
+		}
+		element = next
+	}
+	return purged
+}
+
+// returns the entries that have not expired yet, least recently used first.
+func (c *responseCache) snapshot(now time.Time) []persistedEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make([]persistedEntry, 0, c.order.Len())
+	for element := c.order.Back(); element != nil; element = element.Prev() {
+		entry := element.Value.(*cacheEntry)
+		if now.Before(entry.item.ExpiresAt) {
+			entries = append(entries, persistedEntry{Key: entry.key, Item: entry.item})
+		}
+	}
+	return entries
+}
+
+func (c *responseCache) recordLookup(indexer string, hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lookups, ok := c.lookups[indexer]
+	if !ok {
+		lookups = &cacheLookups{}
+		c.lookups[indexer] = lookups
+	}
+	if hit {
+		lookups.Hits++
+	} else {
+		lookups.Misses++
+	}
+}
+
+// summarises the entries held and the lookups recorded so far, per indexer and in total.
+func (c *responseCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := CacheStats{Indexers: make(map[string]*IndexerCacheStats)}
+	indexerStats := func(indexer string) *IndexerCacheStats {
+		if _, ok := stats.Indexers[indexer]; !ok {
+			stats.Indexers[indexer] = &IndexerCacheStats{}
+		}
+		return stats.Indexers[indexer]
+	}
+
+	for element := c.order.Front(); element != nil; element = element.Next() {
+		item := element.Value.(*cacheEntry).item
+		stats.Entries++
+		indexerStats(item.Indexer).Entries++
+		if stats.Oldest == nil || item.LastFetched.Before(*stats.Oldest) {
+			stats.Oldest = &item.LastFetched
+		}
+		if stats.Newest == nil || item.LastFetched.After(*stats.Newest) {
+			stats.Newest = &item.LastFetched
+		}
+	}
+
+	for indexer, lookups := range c.lookups {
+		stats.Hits += lookups.Hits
+		stats.Misses += lookups.Misses
+		indexerStats(indexer).cacheLookups = *lookups
+	}
+	return stats
+}
+
+func (c *responseCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// returns the key a response is cached under. the indexer is part of the key, as torrent and group IDs are only unique per tracker,
+// and so is a hash of the API key, so a response fetched with one user's key is never served to requests made with another.
+func responseCacheKey(indexer, apiKey, action string, id int) string {
+	return fmt.Sprintf("%s:%s:%sID %d", indexer, apiKeyHash(apiKey), action, id)
+}
+
+// returns the key a torrent looked up by infohash is cached under, kept apart from the ones for torrent IDs.
+func torrentHashCacheKey(indexer, apiKey, hash string) string {
+	return fmt.Sprintf("%s:%s:torrentHash %s", indexer, apiKeyHash(apiKey), hash)
+}
+
+// torrent IDs that infohashes resolved to, keyed by the cache key of the hash. the ID of a torrent never
+// changes, so these do not expire, and they are only dropped all at once when there are too many.
+var hashIDs = struct {
+	sync.Mutex
+	ids map[string]int
+}{ids: make(map[string]int)}
+
+// returns the torrent ID the hash under hashKey is known to belong to.
+func resolveHash(hashKey string) (int, bool) {
+	hashIDs.Lock()
+	defer hashIDs.Unlock()
+	id, ok := hashIDs.ids[hashKey]
+	return id, ok
+}
+
+// remembers the torrent ID the hash under hashKey belongs to. once max_entries hashes are known,
+// one of them is forgotten for every new one, so the others stay resolvable.
+func rememberHash(hashKey string, id int) {
+	hashIDs.Lock()
+	defer hashIDs.Unlock()
+	if _, ok := hashIDs.ids[hashKey]; !ok {
+		for known := range hashIDs.ids {
+			if len(hashIDs.ids) < getCacheMaxEntries() {
+				break
+			}
+			delete(hashIDs.ids, known)
+		}
+	}
+	hashIDs.ids[hashKey] = id
+}
+
+// returns a short, non-reversible identifier for the API key that is safe to keep in cache keys and logs.
+func apiKeyHash(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// stores the responseData in cache with the specified cacheKey, expiring it after the configured TTL.
+// a TTL of zero disables caching.
+func cacheResponseData(cacheKey string, indexer, action string, id int, responseData *ResponseData) {
+	ttl := getCacheTTL(action)
+	if ttl <= 0 {
+		return
 	}
+
+	ttl = jitterTTL(ttl, config.GetConfig().Cache.TTLJitter, rand.Float64())
+
+	now := time.Now()
+	cache.set(cacheKey, CacheItem{
+		Data:        responseData,
+		Indexer:     indexer,
+		Action:      action,
+		ID:          id,
+		LastFetched: now,
+		ExpiresAt:   now.Add(ttl),
+	}, getCacheMaxEntries())
+
+	markCacheDirty()
 }
 
 // checks if there is cached data for a given cache key and indexer,
 // and returns the cached data if it exists and is not expired.
-func checkCache(cacheKey string, indexer string) (*ResponseData, bool) {
-	if cached, ok := cache[cacheKey]; ok && time.Since(cached.LastFetched) < 5*time.Minute {
-		log.Trace().Msgf("[%s] Using cached data for %s", indexer, cacheKey)
-		return cached.Data, true
+func checkCache(ctx context.Context, cacheKey string, indexer string) (*ResponseData, bool) {
+	cached, ok := cache.get(cacheKey)
+	if !ok {
+		cache.recordLookup(indexer, false)
+		return nil, false
+	}
+
+	if getCacheTTL(cached.Action) <= 0 || time.Now().After(cached.ExpiresAt) {
+		cache.remove(cacheKey)
+		cache.recordLookup(indexer, false)
+		return nil, false
+	}
+
+	cache.recordLookup(indexer, true)
+
+	log.Ctx(ctx).Trace().Msgf("[%s] Using cached data for %s", indexer, cacheKey)
+	return cached.Data, true
+}
+
+// returns the key a failed lookup is cached under, kept apart from the responses so the two never mix.
+func failureCacheKey(cacheKey string) string {
+	return "failure:" + cacheKey
+}
+
+// remembers that the tracker answered the lookup with a failure, e.g. a bad ID, for the negative TTL,
+// so retries of the same ID do not hit the API again. a negative TTL of zero disables this.
+func cacheFailure(cacheKey string, indexer, action string, id int, apiErr *APIError) {
+	ttl := getNegativeCacheTTL()
+	if ttl <= 0 {
+		return
+	}
+
+	now := time.Now()
+	cache.set(failureCacheKey(cacheKey), CacheItem{
+		Indexer:     indexer,
+		Action:      action,
+		ID:          id,
+		APIError:    apiErr.Message,
+		LastFetched: now,
+		ExpiresAt:   now.Add(ttl),
+	}, getCacheMaxEntries())
+
+	markCacheDirty()
+}
+
+// returns the cached failure for the lookup, if the tracker rejected it within the negative TTL.
+func checkFailureCache(ctx context.Context, cacheKey string, indexer string) error {
+	key := failureCacheKey(cacheKey)
+	cached, ok := cache.get(key)
+	if !ok {
+		return nil
+	}
+
+	if getNegativeCacheTTL() <= 0 || time.Now().After(cached.ExpiresAt) {
+		cache.remove(key)
+		return nil
+	}
+
+	log.Ctx(ctx).Trace().Msgf("[%s] Using cached failure for %s: %s", indexer, cacheKey, cached.APIError)
+	return &APIError{Indexer: indexer, Message: cached.APIError}
+}
+
+// returns how long responses are cached for.
+func getCacheTTL(action string) time.Duration {
+	cacheCfg := config.GetConfig().Cache
+	if ttl, ok := cacheCfg.ActionTTL[action]; ok {
+		return time.Duration(ttl) * time.Second
+	}
+	return time.Duration(cacheCfg.TTL) * time.Second
+}
+
+// varies the TTL by up to percent in either direction, with random in [0, 1) picking where in that range it lands.
+func jitterTTL(ttl time.Duration, percent int, random float64) time.Duration {
+	if percent <= 0 {
+		return ttl
+	}
+	spread := float64(ttl) * float64(percent) / 100
+	return ttl + time.Duration(spread*(2*random-1))
+}
+
+// returns how long failures from the tracker are cached for.
+func getNegativeCacheTTL() time.Duration {
+	return time.Duration(config.GetConfig().Cache.NegativeTTL) * time.Second
+}
+
+// returns how many responses the cache holds before evicting the least recently used.
+func getCacheMaxEntries() int {
+	if maxEntries := config.GetConfig().Cache.MaxEntries; maxEntries > 0 {
+		return maxEntries
 	}
-	return nil, false
+	return defaultCacheMaxEntries
 }
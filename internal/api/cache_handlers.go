@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// CacheStats is a snapshot of the response cache, served by CacheStatsHandler.
+type CacheStats struct {
+	Entries  int                           `json:"entries"`
+	Hits     uint64                        `json:"hits"`
+	Misses   uint64                        `json:"misses"`
+	Oldest   *time.Time                    `json:"oldest,omitempty"`
+	Newest   *time.Time                    `json:"newest,omitempty"`
+	Indexers map[string]*IndexerCacheStats `json:"indexers"`
+}
+
+type IndexerCacheStats struct {
+	Entries int `json:"entries"`
+	cacheLookups
+}
+
+// CachePurgeRequest selects the entries dropped by CachePurgeHandler. empty fields match everything.
+type CachePurgeRequest struct {
+	Indexer string `json:"indexer,omitempty"`
+	Action  string `json:"action,omitempty"`
+	ID      int    `json:"id,omitempty"`
+}
+
+// checks if the cached item is selected by the purge request.
+func (p CachePurgeRequest) matches(item CacheItem) bool {
+	return (p.Indexer == "" || p.Indexer == item.Indexer) &&
+		(p.Action == "" || p.Action == item.Action) &&
+		(p.ID == 0 || p.ID == item.ID)
+}
+
+// reports what the response cache holds and how often it was hit since startup.
+// it is authenticated the same way as the webhook.
+func CacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := authorizeRequest(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(cache.stats()); err != nil {
+		log.Error().Err(err).Msg("Failed to encode cache stats response")
+	}
+}
+
+// drops cached responses so the next lookup fetches fresh data from the tracker. without a body
+// the whole cache is cleared; a JSON body can narrow it down to an indexer, action and/or ID.
+func CachePurgeHandler(w http.ResponseWriter, r *http.Request) {
+	if err := authorizeRequest(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err := validateRequestMethod(r.Method); err != nil {
+		http.Error(w, err.Error(), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var purgeRequest CachePurgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&purgeRequest); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	purged := cache.purge(purgeRequest.matches)
+	persistCache()
+	log.Info().Str("indexer", purgeRequest.Indexer).Str("action", purgeRequest.Action).Int("id", purgeRequest.ID).Msgf("Purged %d cached responses", purged)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]int{"purged": purged}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode cache purge response")
+	}
+}
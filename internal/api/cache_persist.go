@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/redactedhook/internal/config"
+)
+
+// how often a changed cache is written to persist_path, so storing a response never waits for the disk.
+const cachePersistInterval = 30 * time.Second
+
+var (
+	persistMu  sync.Mutex
+	cacheDirty atomic.Bool // set when the cache changed since it was last written
+)
+
+type persistedEntry struct {
+	Key  string    `json:"key"`
+	Item CacheItem `json:"item"`
+}
+
+// LoadCache warms the response cache from the snapshot at the configured persist path.
+// entries that expired while the service was down are skipped.
+func LoadCache() {
+	path := config.GetConfig().Cache.PersistPath
+	if path == "" {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			cacheBackendErrorsTotal.WithLabelValues("load").Inc()
+			log.Warn().Err(err).Msgf("Failed to read cache snapshot %s", path)
+		}
+		return
+	}
+
+	var entries []persistedEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		cacheBackendErrorsTotal.WithLabelValues("load").Inc()
+		log.Warn().Err(err).Msgf("Failed to parse cache snapshot %s", path)
+		return
+	}
+
+	now := time.Now()
+	loaded := 0
+	for _, entry := range entries {
+		if entry.Item.Data == nil || !now.Before(entry.Item.ExpiresAt) {
+			continue
+		}
+		cache.set(entry.Key, entry.Item, getCacheMaxEntries())
+		loaded++
+	}
+	log.Debug().Msgf("Loaded %d cached responses from %s", loaded, path)
+}
+
+// marks the cache as changed, so it is written with the next flush.
+func markCacheDirty() {
+	cacheDirty.Store(true)
+}
+
+// StartCachePersister writes the cache to the configured persist path every cachePersistInterval while it has
+// changed since the last write. FlushCache writes what is left on shutdown.
+func StartCachePersister() {
+	go func() {
+		ticker := time.NewTicker(cachePersistInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			FlushCache()
+		}
+	}()
+}
+
+// FlushCache writes the cache to the configured persist path if it changed since it was last written.
+func FlushCache() {
+	if cacheDirty.Swap(false) {
+		persistCache()
+	}
+}
+
+// writes the unexpired cache entries to the configured persist path, if any. a failed write only logs a
+// warning, the responses stay cached in memory and the next write tries again.
+func persistCache() {
+	path := config.GetConfig().Cache.PersistPath
+	if path == "" {
+		return
+	}
+
+	persistMu.Lock()
+	defer persistMu.Unlock()
+
+	data, err := json.Marshal(cache.snapshot(time.Now()))
+	if err != nil {
+		cacheBackendErrorsTotal.WithLabelValues("persist").Inc()
+		log.Warn().Err(err).Msg("Failed to encode cache snapshot")
+		return
+	}
+
+	// write to a temporary file first so a crash never leaves a truncated snapshot behind
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		cacheBackendErrorsTotal.WithLabelValues("persist").Inc()
+		log.Warn().Err(err).Msgf("Failed to write cache snapshot %s", tmpPath)
+		return
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		cacheBackendErrorsTotal.WithLabelValues("persist").Inc()
+		log.Warn().Err(err).Msgf("Failed to replace cache snapshot %s", path)
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/s0up4200/redactedhook/internal/config"
+	"golang.org/x/sync/semaphore"
+)
+
+var (
+	httpClient     *http.Client
+	httpClientOnce sync.Once
+
+	requestSemaphore     *semaphore.Weighted
+	requestSemaphoreOnce sync.Once
+)
+
+// returns the User-Agent sent with every API request, user_agent from the config if set.
+func userAgent() string {
+	if ua := config.GetConfig().HTTPClient.UserAgent; ua != "" {
+		return ua
+	}
+	return "redactedhook/" + buildInfo.Version
+}
+
+// returns the shared HTTP client used for all indexer API requests,
+// building its pooled transport from the config on first use. HTTP/2 is used when the
+// tracker supports it, unless http2 is turned off, in which case HTTP/1.1 with keep-alive is used.
+func getHTTPClient() *http.Client {
+	httpClientOnce.Do(func() {
+		clientCfg := config.GetConfig().HTTPClient
+
+		transport := &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:          clientCfg.MaxIdleConns,
+			MaxIdleConnsPerHost:   clientCfg.MaxIdleConnsPerHost,
+			IdleConnTimeout:       time.Duration(clientCfg.IdleConnTimeout) * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+			// a custom dialer turns off HTTP/2 unless it is asked for explicitly
+			ForceAttemptHTTP2: clientCfg.HTTP2,
+		}
+		if !clientCfg.HTTP2 {
+			// a non-nil empty map keeps the transport from negotiating h2, for proxies that mishandle it
+			transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		}
+
+		httpClient = &http.Client{Transport: transport}
+	})
+	return httpClient
+}
+
+// returns the semaphore capping the API requests in flight across all indexers,
+// or nil when max_concurrent_requests is not set.
+func getRequestSemaphore() *semaphore.Weighted {
+	requestSemaphoreOnce.Do(func() {
+		if maxConcurrent := config.GetConfig().HTTPClient.MaxConcurrentRequests; maxConcurrent > 0 {
+			requestSemaphore = semaphore.NewWeighted(int64(maxConcurrent))
+		}
+	})
+	return requestSemaphore
+}
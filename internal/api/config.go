@@ -1,9 +1,20 @@
 package api
 
 import (
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/s0up4200/redactedhook/internal/config"
 )
 
+const (
+	defaultRequestTimeout = 10 * time.Second
+	defaultMaxAttempts    = 3
+	defaultRetryDelay     = 200 * time.Millisecond
+	defaultMaxBodySize    = 5 << 20
+)
+
 // checks if certain fields in the requestData struct are empty or zero,
 // and if so, it populates them with values from the cfg struct.
 func fallbackToConfig(requestData *RequestData) {
@@ -26,18 +37,184 @@ func fallbackToConfig(requestData *RequestData) {
 		requestData.MinRatio = config.Ratio.MinRatio
 	}
 	if requestData.MinSize == 0 {
-		requestData.MinSize = config.ParsedSizes.MinSize
+		requestData.MinSize = ByteSize(config.ParsedSizes.MinSize)
 	}
 	if requestData.MaxSize == 0 {
-		requestData.MaxSize = config.ParsedSizes.MaxSize
+		requestData.MaxSize = ByteSize(config.ParsedSizes.MaxSize)
 	}
 	if requestData.Uploaders == "" {
 		requestData.Uploaders = config.Uploaders.Uploaders
 	}
+	if requestData.AllowedUploaders == "" {
+		requestData.AllowedUploaders = config.Uploaders.Allow
+	}
+	if requestData.BlockedUploaders == "" {
+		requestData.BlockedUploaders = config.Uploaders.Block
+	}
 	if requestData.Mode == "" {
 		requestData.Mode = config.Uploaders.Mode
 	}
 	if requestData.RecordLabel == "" {
 		requestData.RecordLabel = config.RecordLabels.RecordLabels
 	}
+	if requestData.BlockedRecordLabel == "" {
+		requestData.BlockedRecordLabel = config.RecordLabels.BlockedRecordLabels
+	}
+	if requestData.MinLogScore == 0 {
+		requestData.MinLogScore = config.LogScore.MinLogScore
+	}
+	if !requestData.StrictLogScore {
+		requestData.StrictLogScore = config.LogScore.Strict
+	}
+	if !requestData.RequireLog {
+		requestData.RequireLog = config.LogScore.RequireLog
+	}
+	if !requestData.RequireCue {
+		requestData.RequireCue = config.LogScore.RequireCue
+	}
+	if !requestData.FreeleechOnly {
+		requestData.FreeleechOnly = config.Freeleech.FreeleechOnly
+	}
+	if !requestData.SkipFreeleech {
+		requestData.SkipFreeleech = config.Freeleech.SkipFreeleech
+	}
+	if requestData.MinYear == 0 {
+		requestData.MinYear = config.Year.MinYear
+	}
+	if requestData.MaxYear == 0 {
+		requestData.MaxYear = config.Year.MaxYear
+	}
+	if !requestData.AllowUnknownYear {
+		requestData.AllowUnknownYear = config.Year.AllowUnknown
+	}
+	if requestData.Formats == "" {
+		requestData.Formats = config.Format.Formats
+	}
+	if requestData.Encodings == "" {
+		requestData.Encodings = config.Format.Encodings
+	}
+	if requestData.EncodingPreference == "" {
+		requestData.EncodingPreference = config.Format.EncodingPreference
+	}
+	if requestData.Media == "" {
+		requestData.Media = config.Media.Media
+	}
+	if requestData.MinSeeders == 0 {
+		requestData.MinSeeders = config.Seeders.MinSeeders
+	}
+	if requestData.MinLeechers == 0 {
+		requestData.MinLeechers = config.Leechers.MinLeechers
+	}
+	if requestData.MinSnatched == 0 {
+		requestData.MinSnatched = config.Snatched.MinSnatched
+	}
+	if !requestData.SceneOnly {
+		requestData.SceneOnly = config.Scene.SceneOnly
+	}
+	if !requestData.SkipScene {
+		requestData.SkipScene = config.Scene.SkipScene
+	}
+	if !requestData.SkipTrumpable {
+		requestData.SkipTrumpable = config.Trumpable.SkipTrumpable
+	}
+	if !requestData.RequireFeatured {
+		requestData.RequireFeatured = config.Featured.RequireFeatured
+	}
+	if !requestData.SkipFeatured {
+		requestData.SkipFeatured = config.Featured.SkipFeatured
+	}
+	if requestData.CatalogueNumbers == "" {
+		requestData.CatalogueNumbers = config.Catalogue.CatalogueNumbers
+	}
+	if requestData.ReleaseTypes == "" {
+		requestData.ReleaseTypes = strings.Join(config.ReleaseTypes.ReleaseTypes, ",")
+	}
+	if requestData.TagsRequireAny == "" {
+		requestData.TagsRequireAny = strings.Join(config.Tags.RequireAny, ",")
+	}
+	if requestData.TagsExclude == "" {
+		requestData.TagsExclude = strings.Join(config.Tags.Exclude, ",")
+	}
+	if requestData.MaxAge == 0 && config.Age.MaxAge != "" {
+		if maxAge, err := time.ParseDuration(config.Age.MaxAge); err == nil {
+			requestData.MaxAge = Duration(maxAge)
+		}
+	}
+	if requestData.MinRatioBuffer == 0 && config.Ratio.MinRatioBuffer != "" {
+		_ = requestData.MinRatioBuffer.UnmarshalText([]byte(config.Ratio.MinRatioBuffer)) // validated on startup
+	}
+	// slices are copied, so rule groups and routes decoding into them never write to the config
+	if len(requestData.RequireArtist) == 0 {
+		requestData.RequireArtist = append([]string(nil), config.Artists.RequireArtist...)
+	}
+	if len(requestData.RequireArtistRoles) == 0 {
+		requestData.RequireArtistRoles = append([]string(nil), config.Artists.Roles...)
+	}
+	if requestData.MinBitDepth == 0 {
+		requestData.MinBitDepth = config.Audio.MinBitDepth
+	}
+	if requestData.AllowedSampleRates == "" {
+		sampleRates := make([]string, len(config.Audio.AllowedSampleRates))
+		for i, sampleRate := range config.Audio.AllowedSampleRates {
+			sampleRates[i] = strconv.FormatFloat(sampleRate, 'f', -1, 64)
+		}
+		requestData.AllowedSampleRates = strings.Join(sampleRates, ",")
+	}
+	if !requestData.AllowUnknownAudio {
+		requestData.AllowUnknownAudio = config.Audio.AllowUnknown
+	}
+	if len(requestData.GroupIDsAllow) == 0 {
+		requestData.GroupIDsAllow = append([]int(nil), config.GroupIDs.Allow...)
+	}
+	if len(requestData.GroupIDsBlock) == 0 {
+		requestData.GroupIDsBlock = append([]int(nil), config.GroupIDs.Block...)
+	}
+	if !requestData.PreferBestInGroup {
+		requestData.PreferBestInGroup = config.BestInGroup.Enabled
+	}
+	if requestData.QualityPreference == "" {
+		requestData.QualityPreference = strings.Join(config.BestInGroup.Preference, ",")
+	}
+	if requestData.MinFiles == 0 {
+		requestData.MinFiles = config.Files.MinFiles
+	}
+	if requestData.MaxFiles == 0 {
+		requestData.MaxFiles = config.Files.MaxFiles
+	}
+	if requestData.MinScore == 0 {
+		requestData.MinScore = config.Scoring.MinScore
+	}
+}
+
+// returns the configured API request timeout for the indexer, or the default when none is set.
+func getRequestTimeout(indexer string) time.Duration {
+	if timeout := config.GetConfig().Indexers[indexer].Timeout; timeout > 0 {
+		return time.Duration(timeout) * time.Second
+	}
+	return defaultRequestTimeout
+}
+
+// returns the configured number of request attempts and the base delay between them.
+func getRetryPolicy() (int, time.Duration) {
+	httpClient := config.GetConfig().HTTPClient
+
+	maxAttempts := httpClient.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	retryDelay := defaultRetryDelay
+	if httpClient.RetryDelay > 0 {
+		retryDelay = time.Duration(httpClient.RetryDelay) * time.Millisecond
+	}
+
+	return maxAttempts, retryDelay
+}
+
+// returns the largest API response body that will be read, or the default when none is set.
+func getMaxBodySize() int64 {
+	if maxBodySize := config.GetConfig().HTTPClient.MaxBodySize; maxBodySize > 0 {
+		return int64(maxBodySize)
+	}
+	return defaultMaxBodySize
 }
@@ -8,8 +8,31 @@ const (
 )
 
 const (
-	StatusUploaderNotAllowed = http.StatusIMUsed + 1
-	StatusLabelNotAllowed    = http.StatusIMUsed + 2
-	StatusSizeNotAllowed     = http.StatusIMUsed + 3
-	StatusRatioNotAllowed    = http.StatusIMUsed
+	StatusUploaderNotAllowed    = http.StatusIMUsed + 1
+	StatusLabelNotAllowed       = http.StatusIMUsed + 2
+	StatusSizeNotAllowed        = http.StatusIMUsed + 3
+	StatusLogScoreNotAllowed    = http.StatusIMUsed + 4
+	StatusFreeleechNotAllowed   = http.StatusIMUsed + 5
+	StatusYearNotAllowed        = http.StatusIMUsed + 6
+	StatusFormatNotAllowed      = http.StatusIMUsed + 7
+	StatusMediaNotAllowed       = http.StatusIMUsed + 8
+	StatusSeedersNotAllowed     = http.StatusIMUsed + 9
+	StatusSnatchedNotAllowed    = http.StatusIMUsed + 10
+	StatusReleaseNameNotAllowed = http.StatusIMUsed + 11
+	StatusSceneNotAllowed       = http.StatusIMUsed + 12
+	StatusCatalogueNotAllowed   = http.StatusIMUsed + 13
+	StatusReleaseTypeNotAllowed = http.StatusIMUsed + 14
+	StatusTagsNotAllowed        = http.StatusIMUsed + 15
+	StatusAgeNotAllowed         = http.StatusIMUsed + 16
+	StatusArtistNotAllowed      = http.StatusIMUsed + 17
+	StatusAudioNotAllowed       = http.StatusIMUsed + 18
+	StatusGroupNotAllowed       = http.StatusIMUsed + 19
+	StatusRemasterNotAllowed    = http.StatusIMUsed + 20
+	StatusBestInGroupNotAllowed = http.StatusIMUsed + 21
+	StatusLeechersNotAllowed    = http.StatusIMUsed + 22
+	StatusFilesNotAllowed       = http.StatusIMUsed + 23
+	StatusTrumpableNotAllowed   = http.StatusIMUsed + 24
+	StatusFeaturedNotAllowed    = http.StatusIMUsed + 25
+	StatusScoreNotAllowed       = http.StatusIMUsed + 26
+	StatusRatioNotAllowed       = http.StatusIMUsed
 )
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/redactedhook/internal/config"
+)
+
+// responses are kept up to this many bytes, which fits any verdict.
+const maxDebugResponse = 1024
+
+var debugRequests = &debugHistory{}
+
+type debugCaptureKey struct{}
+
+// debugEntry is a webhook request kept in the debug history, with what the tracker reported and the verdict.
+type debugEntry struct {
+	Time       time.Time           `json:"time"`
+	RequestID  string              `json:"request_id"`
+	Route      string              `json:"route,omitempty"`
+	Method     string              `json:"method"`
+	RemoteAddr string              `json:"remote_addr"`
+	UserAgent  string              `json:"user_agent,omitempty"`
+	Duration   float64             `json:"duration_ms"`
+	Request    json.RawMessage     `json:"request,omitempty"` // the decoded payload merged with the config, API keys redacted
+	Torrents   []*evaluatedTorrent `json:"torrents,omitempty"`
+	Status     int                 `json:"status"`
+	Response   json.RawMessage     `json:"response,omitempty"`
+}
+
+// debugHistory is a fixed size ring of the most recent webhook requests, for debug_history.
+type debugHistory struct {
+	mu      sync.Mutex
+	entries []debugEntry
+	next    int
+}
+
+// keeps only the size most recent entries, oldest first. a ring that has wrapped is put back in order
+// even when it grows, so new entries are appended after the newest one. the caller holds h.mu.
+func (h *debugHistory) resize(size int) {
+	if h.next == 0 && len(h.entries) <= size {
+		return
+	}
+	entries := append(append([]debugEntry(nil), h.entries[h.next:]...), h.entries[:h.next]...)
+	if len(entries) > size {
+		entries = entries[len(entries)-size:]
+	}
+	h.entries = entries
+	h.next = 0
+}
+
+// stores the entry, replacing the oldest one once size entries are kept.
+func (h *debugHistory) add(entry debugEntry, size int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.resize(size)
+	if len(h.entries) < size {
+		h.entries = append(h.entries, entry)
+		return
+	}
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % size
+}
+
+// returns up to size of the kept entries, newest first.
+func (h *debugHistory) list(size int) []debugEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.resize(size)
+	entries := make([]debugEntry, 0, len(h.entries))
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		entries = append(entries, h.entries[(h.next+i)%len(h.entries)])
+	}
+	return entries
+}
+
+// debugCapture collects a webhook request for the debug history while it is handled, and the
+// response written for it.
+type debugCapture struct {
+	http.ResponseWriter
+	mu       sync.Mutex
+	start    time.Time
+	entry    debugEntry
+	response bytes.Buffer
+}
+
+// returns a capture of the request, and a context the request and torrents it is evaluated against are recorded with.
+func newDebugCapture(ctx context.Context, w http.ResponseWriter, r *http.Request, requestID, route string) (*debugCapture, context.Context) {
+	capture := &debugCapture{
+		ResponseWriter: w,
+		start:          time.Now(),
+		entry: debugEntry{
+			RequestID:  requestID,
+			Route:      route,
+			Method:     r.Method,
+			RemoteAddr: r.RemoteAddr,
+			UserAgent:  r.UserAgent(),
+		},
+	}
+	return capture, context.WithValue(ctx, debugCaptureKey{}, capture)
+}
+
+func (c *debugCapture) WriteHeader(statusCode int) {
+	if c.entry.Status == 0 {
+		c.entry.Status = statusCode
+	}
+	c.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (c *debugCapture) Write(b []byte) (int, error) {
+	if c.entry.Status == 0 {
+		c.entry.Status = http.StatusOK
+	}
+	if room := maxDebugResponse - c.response.Len(); room > 0 {
+		if len(b) < room {
+			room = len(b)
+		}
+		c.response.Write(b[:room])
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// adds the handled request to the debug history. responses that are not JSON, or were cut off, are kept as a string.
+func (c *debugCapture) store(size int) {
+	c.mu.Lock()
+	entry := c.entry
+	c.mu.Unlock()
+
+	entry.Time = c.start
+	entry.Duration = float64(time.Since(c.start).Microseconds()) / 1000
+	if response := bytes.TrimSpace(c.response.Bytes()); json.Valid(response) {
+		entry.Response = append(json.RawMessage(nil), response...)
+	} else if len(response) > 0 {
+		entry.Response, _ = json.Marshal(string(response))
+	}
+	debugRequests.add(entry, size)
+}
+
+// records the request as it is evaluated, if the context captures one for the debug history.
+func captureRequest(ctx context.Context, requestData *RequestData) {
+	capture, ok := ctx.Value(debugCaptureKey{}).(*debugCapture)
+	if !ok {
+		return
+	}
+
+	redacted := *requestData
+	if redacted.REDKey != "" {
+		redacted.REDKey = "[REDACTED]"
+	}
+	if redacted.OPSKey != "" {
+		redacted.OPSKey = "[REDACTED]"
+	}
+	request, err := json.Marshal(redacted)
+	if err != nil {
+		return
+	}
+
+	capture.mu.Lock()
+	capture.entry.Request = request
+	capture.mu.Unlock()
+}
+
+// records the torrent a check is run against, if the context captures the request for the debug history.
+func captureTorrent(ctx context.Context, torrent *evaluatedTorrent) {
+	capture, ok := ctx.Value(debugCaptureKey{}).(*debugCapture)
+	if !ok {
+		return
+	}
+	capture.mu.Lock()
+	capture.entry.Torrents = append(capture.entry.Torrents, torrent)
+	capture.mu.Unlock()
+}
+
+// serves the webhook requests kept by debug_history, newest first. it is authenticated the same way as the webhook.
+func DebugRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := authorizeRequest(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(debugRequests.list(config.GetConfig().Server.DebugHistory)); err != nil {
+		log.Error().Err(err).Msg("Failed to encode debug requests response")
+	}
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sentinel errors the typed errors below match with errors.Is, so callers can tell failures apart
+// without knowing every type. use errors.As on the types when their fields are needed, e.g. RetryAfter.
+var (
+	ErrRateLimited        = errors.New("rate limited")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrTimeout            = errors.New("timed out")
+	ErrNotFound           = errors.New("not found")
+	ErrTrackerUnavailable = errors.New("tracker unavailable")
+)
+
+// TimeoutError is returned when an indexer does not respond within the configured timeout.
+type TimeoutError struct {
+	Indexer string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("request to %s timed out after %s", e.Indexer, e.Timeout)
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// RetryAfterError is returned when an indexer responds with HTTP 429 and tells us how long to back off.
+type RetryAfterError struct {
+	Indexer    string
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("rate limited by %s, retry after %s", e.Indexer, e.RetryAfter)
+}
+
+func (e *RetryAfterError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// RateLimitError is returned when our own rate limiter for an indexer has no request left right now.
+type RateLimitError struct {
+	Indexer    string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded for %s, retry after %s", e.Indexer, e.RetryAfter.Round(time.Millisecond))
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// HTTPStatusError is returned when a tracker answers with an HTTP error status and no failure body to go with it.
+type HTTPStatusError struct {
+	Indexer    string
+	StatusCode int
+	Endpoint   string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP error: %d from %s", e.StatusCode, e.Endpoint)
+}
+
+func (e *HTTPStatusError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrTrackerUnavailable:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// NonJSONResponseError is returned when a tracker answers with something other than JSON, e.g. an HTML block page.
+type NonJSONResponseError struct {
+	Indexer     string
+	StatusCode  int
+	ContentType string
+	Cloudflare  bool
+}
+
+func (e *NonJSONResponseError) Error() string {
+	if e.Cloudflare {
+		return fmt.Sprintf("%s returned non-JSON response (status %d, likely Cloudflare block)", e.Indexer, e.StatusCode)
+	}
+	return fmt.Sprintf("%s returned non-JSON response (status %d, content type %q)", e.Indexer, e.StatusCode, e.ContentType)
+}
+
+func (e *NonJSONResponseError) Is(target error) bool {
+	return target == ErrTrackerUnavailable
+}
+
+// InvalidJSONError is returned when a JSON API response does not decode into the expected structure.
+type InvalidJSONError struct {
+	Indexer string
+	Err     error
+}
+
+func (e *InvalidJSONError) Error() string {
+	return fmt.Sprintf("invalid JSON response: %v", e.Err)
+}
+
+func (e *InvalidJSONError) Unwrap() error {
+	return e.Err
+}
+
+// ResponseTooLargeError is returned when an API response body is larger than the configured maximum.
+type ResponseTooLargeError struct {
+	Indexer string
+	Limit   int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("%s response exceeds the maximum body size of %d bytes", e.Indexer, e.Limit)
+}
+
+// DecompressionError is returned when a compressed API response cannot be decoded, so it is not mistaken for invalid JSON.
+type DecompressionError struct {
+	Indexer  string
+	Encoding string
+	Err      error
+}
+
+func (e *DecompressionError) Error() string {
+	return fmt.Sprintf("failed to decode %s response from %s: %v", e.Encoding, e.Indexer, e.Err)
+}
+
+func (e *DecompressionError) Unwrap() error {
+	return e.Err
+}
+
+// APIError is returned when the tracker answers with a failure status, e.g. "bad id parameter".
+// these are definitive answers about the request, so they are not retried and are cached briefly.
+type APIError struct {
+	Indexer string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error from %s: %s", e.Indexer, e.Message)
+}
+
+// gazelle answers unknown IDs and hashes with "bad id parameter" and "bad hash parameter".
+func (e *APIError) Is(target error) bool {
+	message := strings.ToLower(e.Message)
+	return target == ErrNotFound && (strings.HasPrefix(message, "bad id") || strings.HasPrefix(message, "bad hash") || strings.Contains(message, "not found"))
+}
+
+// UnreachableError wraps a failure to get an answer from the tracker at all, e.g. a network error or an
+// HTML block page, as opposed to a check that failed or a failure status returned by the tracker.
+type UnreachableError struct {
+	Err error
+}
+
+func (e *UnreachableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UnreachableError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UnreachableError) Is(target error) bool {
+	return target == ErrTrackerUnavailable
+}
+
+// ValidationError is returned when a field of the webhook payload is missing or invalid.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"error"`
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// ScoreError is returned when a torrent scores below min_score with the scoring rules.
+type ScoreError struct {
+	Score    int
+	MinScore int
+}
+
+func (e *ScoreError) Error() string {
+	return fmt.Sprintf("score %d is below the minimum of %d", e.Score, e.MinScore)
+}
+
+// CircuitOpenError is returned without contacting the indexer while its circuit breaker is open.
+type CircuitOpenError struct {
+	Indexer string
+	RetryIn time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker open for %s, retry in %s", e.Indexer, e.RetryIn.Round(time.Second))
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrTrackerUnavailable
+}
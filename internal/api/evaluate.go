@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+// evaluationReport is the body served by EvaluateHandler.
+type evaluationReport struct {
+	Indexer   string      `json:"indexer"`
+	TorrentID int         `json:"torrent_id,omitempty"`
+	Hash      string      `json:"torrent_hash,omitempty"`
+	Approved  bool        `json:"approved"`
+	Reason    string      `json:"reason,omitempty"`
+	Evaluated *evaluation `json:"evaluated"`
+	Score     *int        `json:"score,omitempty"`
+}
+
+// runs the configured filters against a torrent given by indexer and id (or hash) in the query string,
+// and reports every check that passed or failed along with the torrent attributes they saw. unlike the
+// webhook it does not stop at the first failed check, and it is authenticated the same way.
+func EvaluateHandler(w http.ResponseWriter, r *http.Request) {
+	if err := authorizeRequest(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	requestData := RequestData{Indexer: query.Get("indexer"), TorrentHash: query.Get("hash")}
+	if id := query.Get("id"); id != "" {
+		torrentID, err := strconv.Atoi(id)
+		if err != nil {
+			writeJSONError(w, &ValidationError{Field: "id", Message: "invalid id: " + id}, http.StatusBadRequest)
+			return
+		}
+		requestData.TorrentID = torrentID
+	}
+	fallbackToConfig(&requestData)
+	applyAPIKeyHeaders(r.Header, &requestData)
+	requestData.NoCache = query.Get("no_cache") == "true"
+
+	if err := requestData.Validate(); err != nil {
+		writeJSONError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	apiBase, err := determineAPIBase(requestData.Indexer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	requestID := requestIDFromHeader(r.Header.Get("X-Request-ID"))
+	w.Header().Set("X-Request-ID", requestID)
+	logger := log.With().Str("request_id", requestID).Logger()
+	ctx := withScore(withEvaluation(logger.WithContext(r.Context()), true))
+
+	statusCode, err := evaluateRuleGroups(ctx, &requestData, apiBase)
+	if err != nil && isTransientError(err) {
+		handleErrors(w, err, statusCode) // nothing could be evaluated
+		return
+	}
+
+	report := evaluationReport{
+		Indexer:   requestData.Indexer,
+		TorrentID: requestData.TorrentID,
+		Hash:      requestData.TorrentHash,
+		Approved:  err == nil,
+		Evaluated: evaluationFrom(ctx),
+		Score:     scoreFrom(ctx),
+	}
+	if err != nil {
+		report.Reason = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Error().Err(err).Msg("Failed to encode evaluation report")
+	}
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"html"
+	"sync"
+)
+
+type evaluationKey struct{}
+
+// evaluation collects the checks a webhook request passed and the torrent they were run against,
+// for the evaluated object of the approval response when include_evaluated is set. exhaustive
+// evaluations, used by /evaluate, keep going after a failed check and collect the failures too.
+type evaluation struct {
+	mu         sync.Mutex
+	exhaustive bool
+	RuleGroup  string            `json:"rule_group,omitempty"`
+	Checks     []string          `json:"checks"`
+	Failed     []failedCheck     `json:"failed,omitempty"`
+	Torrent    *evaluatedTorrent `json:"torrent,omitempty"`
+}
+
+// failedCheck is a check that did not pass in an exhaustive evaluation.
+type failedCheck struct {
+	Check  string `json:"check"`
+	Reason string `json:"reason"`
+
+	statusCode int
+	err        error
+}
+
+// evaluatedTorrent holds the torrent attributes observed while checking, as the tracker reported them.
+type evaluatedTorrent struct {
+	ReleaseName string `json:"release_name"`
+	Uploader    string `json:"uploader"`
+	Size        int64  `json:"size"`
+	Format      string `json:"format"`
+	Encoding    string `json:"encoding"`
+	Media       string `json:"media"`
+	RecordLabel string `json:"record_label,omitempty"`
+	Year        int    `json:"year,omitempty"`
+	Seeders     int    `json:"seeders"`
+	Leechers    int    `json:"leechers"`
+}
+
+// returns a context that records the checks passed by the request evaluated with it. with exhaustive
+// set, failed checks are recorded as well and do not stop the evaluation.
+func withEvaluation(ctx context.Context, exhaustive bool) context.Context {
+	return context.WithValue(ctx, evaluationKey{}, &evaluation{exhaustive: exhaustive, Checks: []string{}})
+}
+
+// records the outcome of a check, if the context collects an evaluation, and returns the error the
+// evaluation should stop with. in exhaustive evaluations failed checks are only recorded, unless the
+// tracker could not be asked at all, as the remaining checks would fail the same way.
+func checkResult(ctx context.Context, check string, statusCode int, err error) error {
+	e, ok := ctx.Value(evaluationKey{}).(*evaluation)
+	if !ok {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err == nil {
+		e.Checks = append(e.Checks, check)
+		return nil
+	}
+	if !e.exhaustive || isTransientError(err) {
+		return err
+	}
+	e.Failed = append(e.Failed, failedCheck{Check: check, Reason: err.Error(), statusCode: statusCode, err: err})
+	return nil
+}
+
+// returns the status code and error of the first check that failed in an exhaustive evaluation.
+func firstFailure(ctx context.Context) (int, error) {
+	e, ok := ctx.Value(evaluationKey{}).(*evaluation)
+	if !ok {
+		return 0, nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.Failed) == 0 {
+		return 0, nil
+	}
+	return e.Failed[0].statusCode, e.Failed[0].err
+}
+
+// starts over with the checks of the rule group about to run, as only the group that passes counts.
+func startRuleGroup(ctx context.Context, name string) {
+	e, ok := ctx.Value(evaluationKey{}).(*evaluation)
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	e.RuleGroup = name
+	e.Checks = []string{}
+	e.Failed = nil
+	e.mu.Unlock()
+}
+
+// records the attributes of a torrent response the checks are run against, for the evaluation and the debug history.
+func recordTorrent(ctx context.Context, action string, responseData *ResponseData) {
+	if action != "torrent" || responseData.Response.Torrent == nil {
+		return
+	}
+	e, ok := ctx.Value(evaluationKey{}).(*evaluation)
+	_, capturing := ctx.Value(debugCaptureKey{}).(*debugCapture)
+	if !ok && !capturing {
+		return
+	}
+
+	torrent := responseData.Response.Torrent
+	recordLabel := torrent.RecordLabel
+	if recordLabel == "" {
+		recordLabel = responseData.Response.Group.RecordLabel
+	}
+
+	evaluated := &evaluatedTorrent{
+		ReleaseName: html.UnescapeString(torrent.ReleaseName),
+		Uploader:    torrent.Username,
+		Size:        torrent.Size,
+		Format:      torrent.Format,
+		Encoding:    torrent.Encoding,
+		Media:       torrent.Media,
+		RecordLabel: html.UnescapeString(recordLabel),
+		Year:        responseData.Response.Group.Year,
+		Seeders:     torrent.Seeders,
+		Leechers:    torrent.Leechers,
+	}
+	captureTorrent(ctx, evaluated)
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	e.Torrent = evaluated
+	e.mu.Unlock()
+}
+
+// returns the evaluation collected in the context, or nil when include_evaluated is off.
+func evaluationFrom(ctx context.Context) *evaluation {
+	e, _ := ctx.Value(evaluationKey{}).(*evaluation)
+	return e
+}
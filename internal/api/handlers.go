@@ -1,8 +1,14 @@
 package api
 
 import (
-	"fmt"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/rs/zerolog/log"
@@ -11,38 +17,97 @@ import (
 
 // handles webhooks: auth, decode payload, validate, respond 200.
 func WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	handleWebhook(w, r, "")
+}
+
+// RouteHandler returns a webhook handler for the named route, which applies the filters of the route on top of
+// the config before the payload. the filters are looked up on every request, so edits apply without a restart.
+func RouteHandler(route string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handleWebhook(w, r, route)
+	}
+}
+
+// handles a webhook sent to the default path, or to a route when route is set.
+func handleWebhook(w http.ResponseWriter, r *http.Request, route string) {
 	var requestData RequestData
 
-	cfg := config.GetConfig()
-	fallbackToConfig(&requestData)
+	// every log line for this call carries the request ID, so a single decision can be traced
+	requestID := requestIDFromHeader(r.Header.Get("X-Request-ID"))
+	w.Header().Set("X-Request-ID", requestID)
+	logContext := log.With().Str("request_id", requestID)
+	if route != "" {
+		logContext = logContext.Str("route", route)
+	}
+	logger := logContext.Logger()
+	ctx := logger.WithContext(r.Context())
 
-	if err := verifyAPIKey(r.Header.Get("X-API-Token"), cfg.Authorization.APIToken); err != nil {
+	cfg := config.GetConfig()
+	if err := authorizeRequest(r); err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
+	// only authorized requests are kept, so unauthenticated callers can't push the real ones out of the history
+	if cfg.Server.DebugHistory > 0 {
+		var capture *debugCapture
+		capture, ctx = newDebugCapture(ctx, w, r, requestID, route)
+		w = capture
+		defer capture.store(cfg.Server.DebugHistory)
+	}
+
 	if err := validateRequestMethod(r.Method); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := decodeJSONPayload(r, &requestData); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	// routes are only looked up for authorized callers, so their errors never reveal the config
+	fallbackToConfig(&requestData)
+	if route != "" {
+		filters, ok := cfg.Routes[route]
+		if !ok {
+			http.Error(w, "route "+route+" is no longer configured", http.StatusNotFound)
+			return
+		}
+		if err := applyFilters(&requestData, filters); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msgf("Invalid filters for route %s", route)
+			http.Error(w, "invalid filters for route "+route, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if cfg.Authorization.HMACSecret != "" {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if err := verifySignature(body, r.Header.Get("X-Signature"), cfg.Authorization.HMACSecret); err != nil {
+			log.Ctx(ctx).Warn().Str("remote_addr", r.RemoteAddr).Msg("Rejected webhook with an invalid signature")
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if err := decodePayload(r, &requestData); err != nil {
+		writeJSONError(w, err, http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
 
-	if err := validateIndexer(requestData.Indexer); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	applyAPIKeyHeaders(r.Header, &requestData)
+	if strings.Contains(strings.ToLower(r.Header.Get("Cache-Control")), "no-cache") {
+		requestData.NoCache = true
 	}
+	captureRequest(ctx, &requestData)
 
-	if err := validateRequestData(&requestData); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := requestData.Validate(); err != nil {
+		writeJSONError(w, err, http.StatusBadRequest)
 		return
 	}
 
-	log.Info().Msgf("Received data request from %s", r.RemoteAddr)
+	log.Ctx(ctx).Info().Msgf("Received data request from %s", r.RemoteAddr)
 
 	apiBase, err := determineAPIBase(requestData.Indexer)
 	if err != nil {
@@ -50,64 +115,391 @@ func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	dryRun := cfg.Server.DryRun
+	if value := r.URL.Query().Get("dryrun"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "invalid dryrun value: "+value, http.StatusBadRequest)
+			return
+		}
+	}
+
 	reqHeader := make(http.Header)
 	setAuthorizationHeader(&reqHeader, &requestData)
 
+	ctx = withServerTiming(ctx)
+	if len(cfg.Scoring.Rules) > 0 {
+		ctx = withScore(ctx)
+	}
+	if cfg.Server.IncludeEvaluated && len(requestData.TorrentIDs) == 0 {
+		ctx = withEvaluation(ctx, false)
+	}
+
+	if len(requestData.TorrentIDs) > 0 {
+		evaluateBatch(ctx, w, &requestData, apiBase, dryRun)
+		return
+	}
+
+	statusCode, err := evaluateWithFallback(ctx, &requestData, apiBase)
+	setServerTiming(ctx, w.Header())
+	if err != nil {
+		rejectRequest(ctx, w, &requestData, err, statusCode, dryRun)
+		return
+	}
+
+	writeApproval(ctx, w) // HTTP status code 200
+	if dryRun {
+		log.Ctx(ctx).Info().Str("indexer", requestData.Indexer).Int("torrent_id", requestData.TorrentID).Msg("Dry run simulation: conditions met, would respond with status 200")
+		return
+	}
+	log.Ctx(ctx).Info().Str("indexer", requestData.Indexer).Int("torrent_id", requestData.TorrentID).Msg("Conditions met, responding with status 200")
+}
+
+// evaluates the request against its indexer. when that indexer is unavailable and has a fallback_indexer,
+// and the payload names the same release on the fallback, the whole evaluation is repeated there, so every
+// check sees data from one tracker. IDs and hashes differ between trackers, so they cannot be reused.
+func evaluateWithFallback(ctx context.Context, requestData *RequestData, apiBase string) (int, error) {
+	statusCode, err := evaluateRuleGroups(ctx, requestData, apiBase)
+	if err == nil || !errors.Is(err, ErrTrackerUnavailable) {
+		return statusCode, err
+	}
+
+	fallback := config.GetConfig().Indexers[requestData.Indexer].FallbackIndexer
+	if fallback == "" || (requestData.FallbackID == 0 && requestData.FallbackHash == "") {
+		return statusCode, err
+	}
+	fallbackBase, baseErr := determineAPIBase(fallback)
+	if baseErr != nil {
+		return statusCode, err
+	}
+
+	log.Ctx(ctx).Warn().Err(err).Msgf("[%s] Indexer unavailable, checking against fallback indexer %s", requestData.Indexer, fallback)
+	fallbackRequest := *requestData
+	fallbackRequest.Indexer = fallback
+	fallbackRequest.TorrentID = requestData.FallbackID
+	fallbackRequest.TorrentHash = requestData.FallbackHash
+	fallbackRequest.GroupID = 0 // group IDs are tracker specific as well
+	startRuleGroup(ctx, "")
+	return evaluateRuleGroups(ctx, &fallbackRequest, fallbackBase)
+}
+
+// runs the checks enabled in requestData, stopping at the first one that fails.
+// on failure the error is returned along with the status code for that check.
+func runChecks(ctx context.Context, requestData *RequestData, apiBase string) (int, error) {
 	// Call hooks
 
-	if requestData.TorrentID != 0 && (requestData.MinSize != 0 || requestData.MaxSize != 0) {
-		if err := hookSize(&requestData, apiBase); err != nil {
-			handleErrors(w, err, StatusSizeNotAllowed)
-			return
+	if requestData.hasTorrent() && (len(requestData.GroupIDsAllow) > 0 || len(requestData.GroupIDsBlock) > 0) {
+		if err := checkResult(ctx, "group_id", StatusGroupNotAllowed, hookGroupID(ctx, requestData, apiBase)); err != nil {
+			return StatusGroupNotAllowed, err
+		}
+	}
 
+	if requestData.hasTorrent() && (requestData.MinSize != 0 || requestData.MaxSize != 0) {
+		if err := checkResult(ctx, "size", StatusSizeNotAllowed, hookSize(ctx, requestData, apiBase)); err != nil {
+			return StatusSizeNotAllowed, err
 		}
 	}
 
-	if requestData.TorrentID != 0 && requestData.Uploaders != "" {
-		if err := hookUploader(&requestData, apiBase); err != nil {
-			handleErrors(w, err, StatusUploaderNotAllowed)
-			return
+	if requestData.hasTorrent() && (requestData.Uploaders != "" || requestData.AllowedUploaders != "" || requestData.BlockedUploaders != "") {
+		if err := checkResult(ctx, "uploader", StatusUploaderNotAllowed, hookUploader(ctx, requestData, apiBase)); err != nil {
+			return StatusUploaderNotAllowed, err
+		}
+	}
 
+	if requestData.hasTorrent() && (requestData.RecordLabel != "" || requestData.BlockedRecordLabel != "") {
+		if err := checkResult(ctx, "record_label", StatusLabelNotAllowed, hookRecordLabel(ctx, requestData, apiBase)); err != nil {
+			return StatusLabelNotAllowed, err
 		}
 	}
 
-	if requestData.TorrentID != 0 && requestData.RecordLabel != "" {
-		if err := hookRecordLabel(&requestData, apiBase); err != nil {
-			handleErrors(w, err, StatusLabelNotAllowed)
-			return
+	if requestData.hasTorrent() && requestData.MinLogScore != 0 {
+		if err := checkResult(ctx, "log_score", StatusLogScoreNotAllowed, hookLogScore(ctx, requestData, apiBase)); err != nil {
+			return StatusLogScoreNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && (requestData.RequireLog || requestData.RequireCue) {
+		if err := checkResult(ctx, "log_cue", StatusLogScoreNotAllowed, hookLogCue(ctx, requestData, apiBase)); err != nil {
+			return StatusLogScoreNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && (requestData.FreeleechOnly || requestData.SkipFreeleech) {
+		if err := checkResult(ctx, "freeleech", StatusFreeleechNotAllowed, hookFreeleech(ctx, requestData, apiBase)); err != nil {
+			return StatusFreeleechNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && (requestData.MinYear != 0 || requestData.MaxYear != 0) {
+		if err := checkResult(ctx, "year", StatusYearNotAllowed, hookYear(ctx, requestData, apiBase)); err != nil {
+			return StatusYearNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && (requestData.Formats != "" || requestData.Encodings != "") {
+		if err := checkResult(ctx, "format", StatusFormatNotAllowed, hookFormat(ctx, requestData, apiBase)); err != nil {
+			return StatusFormatNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && requestData.Media != "" {
+		if err := checkResult(ctx, "media", StatusMediaNotAllowed, hookMedia(ctx, requestData, apiBase)); err != nil {
+			return StatusMediaNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && requestData.MinSeeders != 0 {
+		if err := checkResult(ctx, "seeders", StatusSeedersNotAllowed, hookSeeders(ctx, requestData, apiBase)); err != nil {
+			return StatusSeedersNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && requestData.MinLeechers != 0 {
+		if err := checkResult(ctx, "leechers", StatusLeechersNotAllowed, hookLeechers(ctx, requestData, apiBase)); err != nil {
+			return StatusLeechersNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && (requestData.MinFiles != 0 || requestData.MaxFiles != 0) {
+		if err := checkResult(ctx, "files", StatusFilesNotAllowed, hookFiles(ctx, requestData, apiBase)); err != nil {
+			return StatusFilesNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && requestData.MinSnatched != 0 {
+		if err := checkResult(ctx, "snatched", StatusSnatchedNotAllowed, hookSnatched(ctx, requestData, apiBase)); err != nil {
+			return StatusSnatchedNotAllowed, err
+		}
+	}
+
+	if releaseName := config.GetConfig().ParsedReleaseName; requestData.hasTorrent() && (len(releaseName.MustMatch) > 0 || len(releaseName.MustNotMatch) > 0) {
+		if err := checkResult(ctx, "release_name", StatusReleaseNameNotAllowed, hookReleaseName(ctx, requestData, apiBase, releaseName)); err != nil {
+			return StatusReleaseNameNotAllowed, err
+		}
+	}
+
+	if cfg := config.GetConfig(); requestData.hasTorrent() && (len(cfg.ParsedRemaster.TitleMustMatch) > 0 || len(cfg.ParsedRemaster.TitleMustNotMatch) > 0 || len(cfg.Remaster.Years) > 0) {
+		if err := checkResult(ctx, "remaster", StatusRemasterNotAllowed, hookRemaster(ctx, requestData, apiBase, cfg.ParsedRemaster, cfg.Remaster.Years)); err != nil {
+			return StatusRemasterNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && (requestData.SceneOnly || requestData.SkipScene) {
+		if err := checkResult(ctx, "scene", StatusSceneNotAllowed, hookScene(ctx, requestData, apiBase)); err != nil {
+			return StatusSceneNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && requestData.SkipTrumpable {
+		if err := checkResult(ctx, "trumpable", StatusTrumpableNotAllowed, hookTrumpable(ctx, requestData, apiBase)); err != nil {
+			return StatusTrumpableNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && (requestData.RequireFeatured || requestData.SkipFeatured) {
+		if err := checkResult(ctx, "featured", StatusFeaturedNotAllowed, hookFeatured(ctx, requestData, apiBase)); err != nil {
+			return StatusFeaturedNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && requestData.CatalogueNumbers != "" {
+		if err := checkResult(ctx, "catalogue", StatusCatalogueNotAllowed, hookCatalogueNumber(ctx, requestData, apiBase)); err != nil {
+			return StatusCatalogueNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && requestData.ReleaseTypes != "" {
+		if err := checkResult(ctx, "release_type", StatusReleaseTypeNotAllowed, hookReleaseType(ctx, requestData, apiBase)); err != nil {
+			return StatusReleaseTypeNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && (requestData.TagsRequireAny != "" || requestData.TagsExclude != "") {
+		if err := checkResult(ctx, "tags", StatusTagsNotAllowed, hookTags(ctx, requestData, apiBase)); err != nil {
+			return StatusTagsNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && requestData.MaxAge != 0 {
+		if err := checkResult(ctx, "age", StatusAgeNotAllowed, hookAge(ctx, requestData, apiBase)); err != nil {
+			return StatusAgeNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && len(requestData.RequireArtist) > 0 {
+		if err := checkResult(ctx, "artist", StatusArtistNotAllowed, hookArtist(ctx, requestData, apiBase)); err != nil {
+			return StatusArtistNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && (requestData.MinBitDepth != 0 || requestData.AllowedSampleRates != "") {
+		if err := checkResult(ctx, "audio", StatusAudioNotAllowed, hookAudio(ctx, requestData, apiBase)); err != nil {
+			return StatusAudioNotAllowed, err
+		}
+	}
+
+	// these run after the other torrent checks, as they are the only ones needing the torrent group as well
+	if requestData.hasTorrent() && requestData.PreferBestInGroup && requestData.QualityPreference != "" {
+		if err := checkResult(ctx, "best_in_group", StatusBestInGroupNotAllowed, hookBestInGroup(ctx, requestData, apiBase)); err != nil {
+			return StatusBestInGroupNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && requestData.EncodingPreference != "" {
+		if err := checkResult(ctx, "encoding_preference", StatusFormatNotAllowed, hookEncodingPreference(ctx, requestData, apiBase)); err != nil {
+			return StatusFormatNotAllowed, err
 		}
 	}
 
 	if requestData.MinRatio != 0 {
-		if err := hookRatio(&requestData, apiBase); err != nil {
-			handleErrors(w, err, StatusRatioNotAllowed)
-			return
+		if err := checkResult(ctx, "ratio", StatusRatioNotAllowed, hookRatio(ctx, requestData, apiBase)); err != nil {
+			return StatusRatioNotAllowed, err
+		}
+	}
+
+	if requestData.hasTorrent() && requestData.MinRatioBuffer != 0 {
+		if err := checkResult(ctx, "ratio_buffer", StatusRatioNotAllowed, hookRatioBuffer(ctx, requestData, apiBase)); err != nil {
+			return StatusRatioNotAllowed, err
+		}
+	}
 
+	// scoring runs last, so it only ranks torrents the filters above allow
+	if rules := config.GetConfig().Scoring.Rules; requestData.hasTorrent() && len(rules) > 0 {
+		if err := checkResult(ctx, "score", StatusScoreNotAllowed, hookScore(ctx, requestData, apiBase, rules)); err != nil {
+			return StatusScoreNotAllowed, err
 		}
 	}
 
-	w.WriteHeader(http.StatusOK) // HTTP status code 200
-	log.Info().Msgf("[%s] Conditions met, responding with status 200", requestData.Indexer)
+	if statusCode, err := firstFailure(ctx); err != nil {
+		return statusCode, err
+	}
+	return http.StatusOK, nil
+}
+
+// responds to a failed check. when the tracker could not be reached and on_api_error is approve, the
+// request is approved instead. in dry run mode the would-be decision is only logged and the
+// request is approved, so filters can be tried against live traffic without gating anything.
+func rejectRequest(ctx context.Context, w http.ResponseWriter, requestData *RequestData, err error, statusCode int, dryRun bool) {
+	if failOpen(err) {
+		log.Ctx(ctx).Warn().Str("indexer", requestData.Indexer).Int("torrent_id", requestData.TorrentID).Str("reason", err.Error()).Msg("FAIL-OPEN: tracker unreachable, approving without checks (on_api_error = approve)")
+		writeVerdict(w, http.StatusOK, "")
+		return
+	}
+	if dryRun {
+		log.Ctx(ctx).Info().Str("indexer", requestData.Indexer).Int("torrent_id", requestData.TorrentID).Int("status", statusCode).Str("reason", err.Error()).Msg("Dry run simulation: would reject")
+		writeVerdict(w, http.StatusOK, "")
+		return
+	}
+	log.Ctx(ctx).Info().Str("indexer", requestData.Indexer).Int("torrent_id", requestData.TorrentID).Int("status", statusCode).Str("reason", err.Error()).Msg("Conditions not met, rejecting")
+	if rejectStatus := config.GetConfig().Server.RejectStatus; rejectStatus != 0 {
+		w = &rejectStatusWriter{ResponseWriter: w, status: rejectStatus}
+	}
+	handleErrors(w, err, statusCode)
+}
+
+// rejectStatusWriter answers every rejection with the configured reject_status instead of the
+// status of the failed check, for clients that compare against a single expected status code.
+type rejectStatusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *rejectStatusWriter) WriteHeader(statusCode int) {
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// verdict is the JSON body sent back to autobrr after the checks have run.
+type verdict struct {
+	Approved  bool        `json:"approved"`
+	Reason    string      `json:"reason,omitempty"`
+	Evaluated *evaluation `json:"evaluated,omitempty"` // only set on approvals when include_evaluated is on
+	Score     *int        `json:"score,omitempty"`     // only set once the torrent was scored with the scoring rules
+}
+
+// writes the decision as a JSON body. any status other than 200 is a rejection, with the reason explaining why.
+func writeVerdict(w http.ResponseWriter, statusCode int, reason string) {
+	encodeVerdict(w, statusCode, verdict{Approved: statusCode == http.StatusOK, Reason: reason})
+}
+
+// writes an approval, with the checks that passed and the torrent they saw when include_evaluated is set,
+// and the score when there are scoring rules.
+func writeApproval(ctx context.Context, w http.ResponseWriter) {
+	encodeVerdict(w, http.StatusOK, verdict{Approved: true, Evaluated: evaluationFrom(ctx), Score: scoreFrom(ctx)})
+}
+
+// writes the verdict as the JSON body of the response.
+func encodeVerdict(w http.ResponseWriter, statusCode int, body verdict) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// writes the error as a JSON body, including the offending field for validation errors.
+func writeJSONError(w http.ResponseWriter, err error, statusCode int) {
+	body := &ValidationError{Message: err.Error()}
+	errors.As(err, &body)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error().Err(err).Msg("Failed to encode error response")
+	}
 }
 
 func handleErrors(w http.ResponseWriter, err error, defaultStatusCode int) {
-	if strings.Contains(err.Error(), "invalid JSON response") {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if errors.Is(err, ErrTimeout) {
+		writeVerdict(w, http.StatusGatewayTimeout, err.Error())
+		return
+	}
+
+	var retryAfterErr *RetryAfterError
+	if errors.As(err, &retryAfterErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfterErr.RetryAfter.Seconds()))))
+		writeVerdict(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rateLimitErr.RetryAfter.Seconds()))))
+		writeVerdict(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+
+	var circuitOpenErr *CircuitOpenError
+	if errors.As(err, &circuitOpenErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(circuitOpenErr.RetryIn.Seconds()))))
+		writeVerdict(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	var nonJSONErr *NonJSONResponseError
+	var tooLargeErr *ResponseTooLargeError
+	var decompressionErr *DecompressionError
+	if errors.As(err, &nonJSONErr) || errors.As(err, &tooLargeErr) || errors.As(err, &decompressionErr) {
+		writeVerdict(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	var invalidJSONErr *InvalidJSONError
+	if errors.As(err, &invalidJSONErr) {
+		writeVerdict(w, http.StatusInternalServerError, "Internal Server Error")
 		return // We're done here, no need to continue.
 	}
 
-	if strings.HasPrefix(err.Error(), "HTTP error:") {
-		// Extract the status code from the error message
-		var statusCode int
-		_, scanErr := fmt.Sscanf(err.Error(), "HTTP error: %d", &statusCode)
-		if scanErr == nil && statusCode != 0 {
-			http.Error(w, err.Error(), statusCode)
-			return // We're done here, too.
-		}
-		// Fallback to internal server error if status code extraction fails
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return // Still done.
+	// the tracker's own status is passed on, e.g. 401 for a revoked API key
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		writeVerdict(w, statusErr.StatusCode, err.Error())
+		return // We're done here, too.
+	}
+
+	var scoreErr *ScoreError
+	if errors.As(err, &scoreErr) {
+		encodeVerdict(w, defaultStatusCode, verdict{Reason: err.Error(), Score: &scoreErr.Score})
+		return
 	}
 
-	http.Error(w, err.Error(), defaultStatusCode)
+	writeVerdict(w, defaultStatusCode, err.Error())
 }
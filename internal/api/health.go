@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/redactedhook/internal/config"
+)
+
+type IndexerHealth struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type HealthResponse struct {
+	Status   string                   `json:"status"`
+	Indexers map[string]IndexerHealth `json:"indexers,omitempty"`
+}
+
+// reports whether the service is up. with ?deep=true it also makes an authenticated
+// API call to every indexer that has an API key configured, and responds 503 if any of them fails.
+// deep checks use the tracker rate limits, so they are authenticated the same way as the webhook.
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	health := HealthResponse{Status: "ok"}
+	statusCode := http.StatusOK
+
+	if r.URL.Query().Get("deep") == "true" {
+		if err := authorizeRequest(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		health.Indexers = checkIndexers(r.Context())
+		for _, indexerHealth := range health.Indexers {
+			if indexerHealth.Status != "ok" {
+				health.Status = "degraded"
+				statusCode = http.StatusServiceUnavailable
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(health); err != nil {
+		log.Error().Err(err).Msg("Failed to encode health response")
+	}
+}
+
+// returns the API key of every indexer that has one configured.
+func configuredAPIKeys() map[string]string {
+	cfg := config.GetConfig()
+	apiKeys := map[string]string{
+		"redacted": cfg.IndexerKeys.REDKey,
+		"ops":      cfg.IndexerKeys.OPSKey,
+	}
+	for name, profile := range cfg.Indexers {
+		if !config.IsBuiltinIndexer(name) {
+			apiKeys[name] = profile.APIKey
+		}
+	}
+	for indexer, apiKey := range apiKeys {
+		if apiKey == "" {
+			delete(apiKeys, indexer)
+		}
+	}
+	return apiKeys
+}
+
+// makes a lightweight index call against every indexer with a configured API key.
+func checkIndexers(ctx context.Context) map[string]IndexerHealth {
+	results := make(map[string]IndexerHealth)
+	for indexer, apiKey := range configuredAPIKeys() {
+		if err := checkIndexer(ctx, indexer, apiKey); err != nil {
+			log.Warn().Err(err).Msgf("[%s] Health check failed", indexer)
+			results[indexer] = IndexerHealth{Status: "error", Error: err.Error()}
+			continue
+		}
+		results[indexer] = IndexerHealth{Status: "ok"}
+	}
+	return results
+}
+
+func checkIndexer(ctx context.Context, indexer, apiKey string) error {
+	apiBase, err := determineAPIBase(indexer)
+	if err != nil {
+		return err
+	}
+
+	limiter := getLimiter(indexer)
+	if limiter == nil {
+		return fmt.Errorf("could not get rate limiter for indexer: %s", indexer)
+	}
+
+	return makeRequest(ctx, apiBase+"?action=index", apiKey, limiter, indexer, &ResponseData{action: "index"})
+}
+
+// VerifyAPIKeys makes an index call with every configured API key and logs whether it was accepted.
+// only keys the tracker explicitly rejects are returned as an error, network trouble is logged and
+// skipped so an unreachable tracker does not keep the service from starting.
+func VerifyAPIKeys(ctx context.Context) error {
+	apiKeys := configuredAPIKeys()
+	indexers := make([]string, 0, len(apiKeys))
+	for indexer := range apiKeys {
+		indexers = append(indexers, indexer)
+	}
+	sort.Strings(indexers)
+
+	var rejected []string
+	for _, indexer := range indexers {
+		err := checkIndexer(ctx, indexer, apiKeys[indexer])
+		switch {
+		case err == nil:
+			log.Info().Msgf("[%s] API key is valid", indexer)
+		case isKeyRejected(err):
+			log.Error().Err(err).Msgf("[%s] API key was rejected", indexer)
+			rejected = append(rejected, indexer)
+		default:
+			log.Warn().Err(err).Msgf("[%s] Could not verify API key, continuing", indexer)
+		}
+	}
+
+	if len(rejected) > 0 {
+		return fmt.Errorf("API key rejected by %s", strings.Join(rejected, ", "))
+	}
+	return nil
+}
+
+// checks if the tracker refused the API key itself, as opposed to not being reachable.
+func isKeyRejected(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) || errors.Is(err, ErrUnauthorized)
+}
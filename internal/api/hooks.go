@@ -1,77 +1,106 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"html"
+	"math"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/inhies/go-bytesize"
 	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/redactedhook/internal/config"
 )
 
 // checks if the uploader is allowed based on the requestData.
-func hookUploader(requestData *RequestData, apiBase string) error {
+// uploaders are matched case-insensitively, and a blocked uploader is rejected even if it is also allowed.
+func hookUploader(ctx context.Context, requestData *RequestData, apiBase string) error {
 
-	torrentData, err := fetchResponseData(requestData, requestData.TorrentID, "torrent", apiBase)
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
 	if err != nil {
 		return err
 	}
 
 	username := torrentData.Response.Torrent.Username
-	usernames := strings.Split(requestData.Uploaders, ",")
-	for i, uname := range usernames {
-		usernames[i] = strings.TrimSpace(uname)
+	allowed := parseList(requestData.AllowedUploaders)
+	blocked := parseList(requestData.BlockedUploaders)
+
+	// the original uploaders list acts as an allow or block list depending on the mode
+	switch requestData.Mode {
+	case "whitelist":
+		allowed = append(allowed, parseList(requestData.Uploaders)...)
+	case "blacklist":
+		blocked = append(blocked, parseList(requestData.Uploaders)...)
 	}
 
-	usernamesStr := strings.Join(usernames, ", ")
-	log.Trace().Msgf("[%s] Requested uploaders [%s]: %s", requestData.Indexer, requestData.Mode, usernamesStr)
+	log.Ctx(ctx).Trace().Msgf("[%s] Requested uploaders [allowed]: %s [blocked]: %s", requestData.Indexer, strings.Join(allowed, ", "), strings.Join(blocked, ", "))
 
-	isListed := false
-	for _, uname := range usernames {
-		if uname == username {
-			isListed = true
-			break
-		}
+	normalizedUsername := foldString(strings.TrimSpace(username))
+	if contains(blocked, normalizedUsername) {
+		log.Ctx(ctx).Debug().Msgf("[%s] Uploader (%s) is blocked", requestData.Indexer, username)
+		return fmt.Errorf("uploader blocklisted: %s", username)
 	}
 
-	if (requestData.Mode == "blacklist" && isListed) || (requestData.Mode == "whitelist" && !isListed) {
-		log.Debug().Msgf("[%s] Uploader (%s) is not allowed", requestData.Indexer, username)
-		return fmt.Errorf("uploader is not allowed")
+	if len(allowed) > 0 && !contains(allowed, normalizedUsername) {
+		log.Ctx(ctx).Debug().Msgf("[%s] Uploader (%s) is not allowed", requestData.Indexer, username)
+		return fmt.Errorf("uploader not allowlisted: %s", username)
 	}
 	return nil
 }
 
 // checks if the record label is allowed based on the requestData.
-func hookRecordLabel(requestData *RequestData, apiBase string) error {
-	torrentData, err := fetchResponseData(requestData, requestData.TorrentID, "torrent", apiBase)
+// a label on the blocked list is always rejected, and when allowed labels are set the label must be one of them.
+func hookRecordLabel(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
 	if err != nil {
 		return err
 	}
 
-	recordLabel := strings.ToLower(strings.TrimSpace(html.UnescapeString(torrentData.Response.Torrent.RecordLabel)))
+	recordLabel := torrentData.Response.Torrent.RecordLabel
+	if strings.TrimSpace(recordLabel) == "" {
+		recordLabel = torrentData.Response.Group.RecordLabel // not a remaster, use the original release label
+	}
+	recordLabel = foldString(strings.TrimSpace(html.UnescapeString(recordLabel)))
 	name := torrentData.Response.Group.Name
 
+	if requestData.BlockedRecordLabel != "" && recordLabel != "" {
+		blockedRecordLabels := normalizeLabels(strings.Split(requestData.BlockedRecordLabel, ","))
+		blockedRecordLabelsStr := strings.Join(blockedRecordLabels, ", ")
+		log.Ctx(ctx).Trace().Msgf("[%s] Blocked record labels: [%s]", requestData.Indexer, blockedRecordLabelsStr)
+
+		if contains(blockedRecordLabels, recordLabel) {
+			log.Ctx(ctx).Debug().Msgf("[%s] The record label '%s' is included in the blocked record labels: [%s]", requestData.Indexer, recordLabel, blockedRecordLabelsStr)
+			return fmt.Errorf("record label blocklisted: %s", recordLabel)
+		}
+	}
+
+	if requestData.RecordLabel == "" {
+		return nil
+	}
+
 	requestedRecordLabels := normalizeLabels(strings.Split(requestData.RecordLabel, ","))
 	if recordLabel == "" {
-		log.Debug().Msgf("[%s] No record label found for release: %s", requestData.Indexer, name)
-		return fmt.Errorf("record label not allowed")
+		log.Ctx(ctx).Debug().Str("indexer", requestData.Indexer).Int("torrent_id", requestData.TorrentID).Str("release_name", name).Msg("No record label found for release")
+		return fmt.Errorf("record label unknown")
 	}
 
 	recordLabelsStr := strings.Join(requestedRecordLabels, ", ")
-	log.Trace().Msgf("[%s] Requested record labels: [%s]", requestData.Indexer, recordLabelsStr)
+	log.Ctx(ctx).Trace().Msgf("[%s] Requested record labels: [%s]", requestData.Indexer, recordLabelsStr)
 
 	isRecordLabelPresent := contains(requestedRecordLabels, recordLabel)
 	if !isRecordLabelPresent {
-		log.Debug().Msgf("[%s] The record label '%s' is not included in the requested record labels: [%s]", requestData.Indexer, recordLabel, recordLabelsStr)
-		return fmt.Errorf("record label not allowed")
+		log.Ctx(ctx).Debug().Msgf("[%s] The record label '%s' is not included in the requested record labels: [%s]", requestData.Indexer, recordLabel, recordLabelsStr)
+		return fmt.Errorf("record label not allowed: %s", recordLabel)
 	}
 
 	return nil
 }
 
 // checks if the torrent size is within the allowed range based on the requestData.
-func hookSize(requestData *RequestData, apiBase string) error {
-	torrentData, err := fetchResponseData(requestData, requestData.TorrentID, "torrent", apiBase)
+func hookSize(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
 	if err != nil {
 		return err
 	}
@@ -80,35 +109,715 @@ func hookSize(requestData *RequestData, apiBase string) error {
 	minSize := bytesize.ByteSize(requestData.MinSize)
 	maxSize := bytesize.ByteSize(requestData.MaxSize)
 
-	log.Trace().Msgf("[%s] Torrent size: %s, Requested size range: %s - %s", requestData.Indexer, torrentSize, requestData.MinSize, requestData.MaxSize)
+	log.Ctx(ctx).Trace().Msgf("[%s] Torrent size: %s, Requested size range: %s - %s", requestData.Indexer, torrentSize, requestData.MinSize, requestData.MaxSize)
+
+	if requestData.MinSize != 0 && torrentSize < minSize {
+		log.Ctx(ctx).Debug().Msgf("[%s] Torrent size %s is outside the requested size range: %s to %s", requestData.Indexer, torrentSize, minSize, maxSize)
+		return fmt.Errorf("torrent size %s is below the minimum size %s", torrentSize, minSize)
+	}
+
+	if requestData.MaxSize != 0 && torrentSize > maxSize {
+		log.Ctx(ctx).Debug().Msgf("[%s] Torrent size %s is outside the requested size range: %s to %s", requestData.Indexer, torrentSize, minSize, maxSize)
+		return fmt.Errorf("torrent size %s is above the maximum size %s", torrentSize, maxSize)
+	}
+
+	return nil
+
+}
+
+// checks if the log score of a CD rip meets the minimum based on the requestData.
+// releases from other media carry no log and are exempt unless StrictLogScore is set.
+func hookLogScore(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	media := torrentData.Response.Torrent.Media
+	logScore := torrentData.Response.Torrent.LogScore
+
+	log.Ctx(ctx).Debug().Msgf("[%s] Log score: %d (Media: %s, HasLog: %t), Requested minimum: %d", requestData.Indexer, logScore, media, torrentData.Response.Torrent.HasLog, requestData.MinLogScore)
+
+	if media != "CD" && !requestData.StrictLogScore {
+		return nil
+	}
+
+	if logScore < requestData.MinLogScore {
+		log.Ctx(ctx).Debug().Msgf("[%s] Log score %d is below the requested minimum %d", requestData.Indexer, logScore, requestData.MinLogScore)
+		return fmt.Errorf("log score %d is below the minimum log score %d", logScore, requestData.MinLogScore)
+	}
+
+	return nil
+}
+
+// checks that a CD rip comes with a log and/or cue file, as requested. releases from other media
+// never have them and are exempt, unless strict_log_score is set.
+func hookLogCue(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	torrent := torrentData.Response.Torrent
+	log.Ctx(ctx).Debug().Msgf("[%s] Media: %s, HasLog: %t, HasCue: %t, RequireLog: %t, RequireCue: %t", requestData.Indexer, torrent.Media, torrent.HasLog, torrent.HasCue, requestData.RequireLog, requestData.RequireCue)
+
+	if torrent.Media != "CD" && !requestData.StrictLogScore {
+		return nil
+	}
+
+	if requestData.RequireLog && !torrent.HasLog {
+		return fmt.Errorf("torrent has no log")
+	}
+
+	if requestData.RequireCue && !torrent.HasCue {
+		return fmt.Errorf("torrent has no cue")
+	}
+
+	return nil
+}
+
+// checks if the freeleech status of the torrent is allowed based on the requestData.
+// neutral leech counts as freeleech, since it does not count towards download either.
+func hookFreeleech(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	freeTorrent := torrentData.Response.Torrent.FreeTorrent
+	isFree := freeTorrent.FreeLeech() || freeTorrent.NeutralLeech()
+
+	log.Ctx(ctx).Trace().Msgf("[%s] Freeleech: %t, Neutral leech: %t", requestData.Indexer, freeTorrent.FreeLeech(), freeTorrent.NeutralLeech())
 
-	if (requestData.MinSize != 0 && torrentSize < minSize) ||
-		(requestData.MaxSize != 0 && torrentSize > maxSize) {
-		log.Debug().Msgf("[%s] Torrent size %s is outside the requested size range: %s to %s", requestData.Indexer, torrentSize, minSize, maxSize)
-		return fmt.Errorf("torrent size is outside the requested size range")
+	if requestData.FreeleechOnly && !isFree {
+		log.Ctx(ctx).Debug().Msgf("[%s] Torrent is not freeleech", requestData.Indexer)
+		return fmt.Errorf("torrent is not freeleech")
+	}
+
+	if requestData.SkipFreeleech && isFree {
+		log.Ctx(ctx).Debug().Msgf("[%s] Torrent is freeleech", requestData.Indexer)
+		return fmt.Errorf("torrent is freeleech")
 	}
 
 	return nil
+}
+
+// checks if the release year of the torrent group is within the allowed range based on the requestData.
+func hookYear(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	year := torrentData.Response.Group.Year
+
+	log.Ctx(ctx).Trace().Msgf("[%s] Release year: %d, Requested year range: %d - %d", requestData.Indexer, year, requestData.MinYear, requestData.MaxYear)
 
+	if year == 0 {
+		if requestData.AllowUnknownYear {
+			return nil
+		}
+		log.Ctx(ctx).Debug().Str("indexer", requestData.Indexer).Int("torrent_id", requestData.TorrentID).Str("release_name", torrentData.Response.Group.Name).Msg("No release year found for release")
+		return fmt.Errorf("release year is unknown")
+	}
+
+	if requestData.MinYear != 0 && year < requestData.MinYear {
+		log.Ctx(ctx).Debug().Msgf("[%s] Release year %d is before the minimum year %d", requestData.Indexer, year, requestData.MinYear)
+		return fmt.Errorf("release year %d is before the minimum year %d", year, requestData.MinYear)
+	}
+
+	if requestData.MaxYear != 0 && year > requestData.MaxYear {
+		log.Ctx(ctx).Debug().Msgf("[%s] Release year %d is after the maximum year %d", requestData.Indexer, year, requestData.MaxYear)
+		return fmt.Errorf("release year %d is after the maximum year %d", year, requestData.MaxYear)
+	}
+
+	return nil
+}
+
+// checks if the format and encoding of the torrent are allowed based on the requestData.
+// both are matched exactly against the tracker's canonical names, e.g. "FLAC" and "24bit Lossless".
+func hookFormat(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	format := torrentData.Response.Torrent.Format
+	encoding := torrentData.Response.Torrent.Encoding
+
+	log.Ctx(ctx).Trace().Msgf("[%s] Format: %s, Encoding: %s, Requested formats: [%s], Requested encodings: [%s]", requestData.Indexer, format, encoding, requestData.Formats, requestData.Encodings)
+
+	if formats := splitList(requestData.Formats); len(formats) > 0 && !contains(formats, format) {
+		log.Ctx(ctx).Debug().Msgf("[%s] Format %s is not included in the requested formats: [%s]", requestData.Indexer, format, strings.Join(formats, ", "))
+		return fmt.Errorf("format %s is not allowed", format)
+	}
+
+	if encodings := splitList(requestData.Encodings); len(encodings) > 0 && !contains(encodings, encoding) {
+		log.Ctx(ctx).Debug().Msgf("[%s] Encoding %s is not included in the requested encodings: [%s]", requestData.Indexer, encoding, strings.Join(encodings, ", "))
+		return fmt.Errorf("encoding %s is not allowed", encoding)
+	}
+
+	return nil
+}
+
+// checks if the media of the torrent is allowed based on the requestData.
+func hookMedia(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	media := torrentData.Response.Torrent.Media
+	requestedMedia := parseList(requestData.Media)
+
+	log.Ctx(ctx).Debug().Msgf("[%s] Media: %s, Requested media: [%s]", requestData.Indexer, media, strings.Join(requestedMedia, ", "))
+
+	if len(requestedMedia) > 0 && !contains(requestedMedia, foldString(media)) {
+		log.Ctx(ctx).Debug().Msgf("[%s] Media %s is not included in the requested media", requestData.Indexer, media)
+		return fmt.Errorf("media %s is not allowed", media)
+	}
+
+	return nil
+}
+
+// checks if the torrent has at least the requested number of seeders.
+// seeder counts change quickly, so they are only as fresh as the cache TTL allows.
+func hookSeeders(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	seeders := torrentData.Response.Torrent.Seeders
+
+	log.Ctx(ctx).Debug().Msgf("[%s] Seeders: %d, Requested minimum: %d", requestData.Indexer, seeders, requestData.MinSeeders)
+
+	if seeders < requestData.MinSeeders {
+		log.Ctx(ctx).Debug().Msgf("[%s] Seeders %d is below the requested minimum %d", requestData.Indexer, seeders, requestData.MinSeeders)
+		return fmt.Errorf("seeders %d is below the minimum seeders %d", seeders, requestData.MinSeeders)
+	}
+
+	return nil
+}
+
+// checks if the torrent has at least the requested number of leechers.
+// like seeders, leecher counts are only as fresh as the cache TTL allows.
+func hookLeechers(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	leechers := torrentData.Response.Torrent.Leechers
+
+	log.Ctx(ctx).Debug().Msgf("[%s] Leechers: %d, Requested minimum: %d", requestData.Indexer, leechers, requestData.MinLeechers)
+
+	if leechers < requestData.MinLeechers {
+		log.Ctx(ctx).Debug().Msgf("[%s] Leechers %d is below the requested minimum %d", requestData.Indexer, leechers, requestData.MinLeechers)
+		return fmt.Errorf("leechers %d is below the minimum leechers %d", leechers, requestData.MinLeechers)
+	}
+
+	return nil
+}
+
+// checks if the torrent has been snatched at least the requested number of times.
+// like seeders, snatch counts are only as fresh as the cache TTL allows.
+func hookSnatched(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	snatched := torrentData.Response.Torrent.Snatched
+
+	log.Ctx(ctx).Debug().Msgf("[%s] Snatched: %d, Requested minimum: %d", requestData.Indexer, snatched, requestData.MinSnatched)
+
+	if snatched < requestData.MinSnatched {
+		log.Ctx(ctx).Debug().Msgf("[%s] Snatched %d is below the requested minimum %d", requestData.Indexer, snatched, requestData.MinSnatched)
+		return fmt.Errorf("snatched %d times, below the minimum snatched %d", snatched, requestData.MinSnatched)
+	}
+
+	return nil
+}
+
+// checks the HTML-unescaped release name against the configured regexes.
+// every must_match pattern has to match, and none of the must_not_match patterns may.
+func hookReleaseName(ctx context.Context, requestData *RequestData, apiBase string, patterns config.ParsedReleaseName) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	releaseName := html.UnescapeString(torrentData.Response.Torrent.ReleaseName)
+
+	for _, pattern := range patterns.MustNotMatch {
+		if pattern.MatchString(releaseName) {
+			log.Ctx(ctx).Debug().Msgf("[%s] Release name %s matches %s", requestData.Indexer, releaseName, pattern)
+			return fmt.Errorf("release name matches %s", pattern)
+		}
+	}
+
+	for _, pattern := range patterns.MustMatch {
+		if !pattern.MatchString(releaseName) {
+			log.Ctx(ctx).Debug().Msgf("[%s] Release name %s does not match %s", requestData.Indexer, releaseName, pattern)
+			return fmt.Errorf("release name does not match %s", pattern)
+		}
+	}
+
+	return nil
+}
+
+// checks the HTML-unescaped remaster title against the configured regexes and the edition year
+// against the allowed years. torrents without a remaster year use the year of the original release.
+func hookRemaster(ctx context.Context, requestData *RequestData, apiBase string, patterns config.ParsedRemaster, years []int) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	title := html.UnescapeString(torrentData.Response.Torrent.RemasterTitle)
+
+	for _, pattern := range patterns.TitleMustNotMatch {
+		if pattern.MatchString(title) {
+			log.Ctx(ctx).Debug().Msgf("[%s] Remaster title %q matches %s", requestData.Indexer, title, pattern)
+			return fmt.Errorf("remaster title matches %s", pattern)
+		}
+	}
+
+	for _, pattern := range patterns.TitleMustMatch {
+		if !pattern.MatchString(title) {
+			log.Ctx(ctx).Debug().Msgf("[%s] Remaster title %q does not match %s", requestData.Indexer, title, pattern)
+			return fmt.Errorf("remaster title does not match %s", pattern)
+		}
+	}
+
+	if len(years) == 0 {
+		return nil
+	}
+
+	year := torrentData.Response.Torrent.RemasterYear
+	if year == 0 {
+		year = torrentData.Response.Group.Year
+	}
+	for _, allowed := range years {
+		if year == allowed {
+			return nil
+		}
+	}
+
+	log.Ctx(ctx).Debug().Msgf("[%s] Edition year %d is not in the allowed years %v", requestData.Indexer, year, years)
+	return fmt.Errorf("edition year %d is not allowed", year)
+}
+
+// checks if the scene flag of the torrent is allowed based on the requestData.
+func hookScene(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	scene := torrentData.Response.Torrent.Scene
+
+	log.Ctx(ctx).Debug().Msgf("[%s] Scene: %t, SceneOnly: %t, SkipScene: %t", requestData.Indexer, scene, requestData.SceneOnly, requestData.SkipScene)
+
+	if requestData.SceneOnly && !scene {
+		return fmt.Errorf("torrent is not a scene release")
+	}
+
+	if requestData.SkipScene && scene {
+		return fmt.Errorf("torrent is a scene release")
+	}
+
+	return nil
+}
+
+// checks if the torrent is flagged as reported or trumpable. the flags can change at any time, so like
+// seeders they are only as fresh as the cache TTL allows. trackers that do not expose a flag never reject.
+func hookTrumpable(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	reported := torrentData.Response.Torrent.Reported
+	trumpable := torrentData.Response.Torrent.Trumpable
+
+	log.Ctx(ctx).Debug().Msgf("[%s] Reported: %s, Trumpable: %s, SkipTrumpable: %t", requestData.Indexer, formatFlag(reported), formatFlag(trumpable), requestData.SkipTrumpable)
+
+	if reported != nil && *reported {
+		return fmt.Errorf("torrent is reported")
+	}
+
+	if trumpable != nil && *trumpable {
+		return fmt.Errorf("torrent is trumpable")
+	}
+
+	return nil
+}
+
+// checks if the featured flag of the torrent is allowed based on the requestData. the flag is read from the
+// field featured_flag names for the indexer. on indexers without one, require_featured rejects every torrent.
+func hookFeatured(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	flag := featuredFlag(requestData.Indexer)
+	featured, ok := isFeatured(flag, torrentData)
+	if !ok {
+		log.Ctx(ctx).Debug().Msgf("[%s] Indexer has no featured flag, RequireFeatured: %t, SkipFeatured: %t", requestData.Indexer, requestData.RequireFeatured, requestData.SkipFeatured)
+		if requestData.RequireFeatured {
+			return fmt.Errorf("indexer %s does not flag featured releases", requestData.Indexer)
+		}
+		return nil
+	}
+
+	log.Ctx(ctx).Debug().Msgf("[%s] Featured (%s): %t, RequireFeatured: %t, SkipFeatured: %t", requestData.Indexer, flag, featured, requestData.RequireFeatured, requestData.SkipFeatured)
+
+	if requestData.RequireFeatured && !featured {
+		return fmt.Errorf("torrent is not featured")
+	}
+
+	if requestData.SkipFeatured && featured {
+		return fmt.Errorf("torrent is featured")
+	}
+
+	return nil
+}
+
+// checks if the catalogue number is one of the requested ones. the remaster catalogue number is used
+// when set, otherwise the one of the original release. matching ignores case and extra whitespace.
+func hookCatalogueNumber(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	catalogueNumber := torrentData.Response.Torrent.CatalogueNumber
+	if strings.TrimSpace(catalogueNumber) == "" {
+		catalogueNumber = torrentData.Response.Group.CatalogueNumber // not a remaster, use the original release catalogue number
+	}
+	catalogueNumber = normalizeCatalogueNumber(catalogueNumber)
+
+	var requested []string
+	for _, number := range splitList(requestData.CatalogueNumbers) {
+		requested = append(requested, normalizeCatalogueNumber(number))
+	}
+
+	log.Ctx(ctx).Debug().Msgf("[%s] Catalogue number: %s, Requested catalogue numbers: [%s]", requestData.Indexer, catalogueNumber, strings.Join(requested, ", "))
+
+	if catalogueNumber == "" {
+		return fmt.Errorf("catalogue number unknown")
+	}
+
+	if !contains(requested, catalogueNumber) {
+		return fmt.Errorf("catalogue number not allowed: %s", catalogueNumber)
+	}
+
+	return nil
+}
+
+// checks if the release type of the torrent group is one of the requested ones.
+func hookReleaseType(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	releaseType := torrentData.Response.Group.ReleaseType
+	releaseTypeName := config.ReleaseTypeName(releaseType)
+
+	var requested []int
+	for _, name := range splitList(requestData.ReleaseTypes) {
+		if code, ok := config.ReleaseTypeCode(name); ok {
+			requested = append(requested, code)
+		}
+	}
+
+	log.Ctx(ctx).Debug().Msgf("[%s] Release type: %s (%d), Requested release types: [%s]", requestData.Indexer, releaseTypeName, releaseType, requestData.ReleaseTypes)
+
+	for _, code := range requested {
+		if code == releaseType {
+			return nil
+		}
+	}
+	return fmt.Errorf("release type %s is not allowed", releaseTypeName)
+}
+
+// checks the tags of the torrent group: at least one of the required tags must be present,
+// and none of the excluded ones. tags are compared in the tracker's dotted format, e.g. "hip.hop".
+func hookTags(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	var tags []string
+	for _, tag := range torrentData.Response.Group.Tags {
+		tags = append(tags, normalizeTag(tag))
+	}
+
+	log.Ctx(ctx).Debug().Msgf("[%s] Tags: [%s], Required tags: [%s], Excluded tags: [%s]", requestData.Indexer, strings.Join(tags, ", "), requestData.TagsRequireAny, requestData.TagsExclude)
+
+	for _, excluded := range splitList(requestData.TagsExclude) {
+		if contains(tags, normalizeTag(excluded)) {
+			return fmt.Errorf("tag excluded: %s", normalizeTag(excluded))
+		}
+	}
+
+	required := splitList(requestData.TagsRequireAny)
+	for _, tag := range required {
+		if contains(tags, normalizeTag(tag)) {
+			return nil
+		}
+	}
+	if len(required) > 0 {
+		return fmt.Errorf("none of the required tags present: %s", requestData.TagsRequireAny)
+	}
+
+	return nil
+}
+
+// checks if the torrent was uploaded within max_age. an upload time in the future, e.g. from clock skew, counts as fresh.
+func hookAge(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	uploaded := torrentData.Response.Torrent.Time
+	maxAge := time.Duration(requestData.MaxAge)
+
+	if uploaded.IsZero() {
+		log.Ctx(ctx).Debug().Msgf("[%s] Upload time unknown, Max age: %s", requestData.Indexer, maxAge)
+		return fmt.Errorf("upload time unknown")
+	}
+
+	age := time.Since(uploaded.Time)
+	log.Ctx(ctx).Debug().Msgf("[%s] Uploaded: %s (age %s), Max age: %s", requestData.Indexer, uploaded.Format(time.RFC3339), age.Round(time.Second), maxAge)
+
+	if age > maxAge {
+		return fmt.Errorf("torrent uploaded %s ago, older than max age %s", age.Round(time.Second), maxAge)
+	}
+
+	return nil
+}
+
+// returns the user ID configured for the indexer, used to look up the user's stats.
+func getUserID(requestData *RequestData) int {
+	switch requestData.Indexer {
+	case "redacted":
+		return requestData.REDUserID
+	case "ops":
+		return requestData.OPSUserID
+	default:
+		return config.GetConfig().Indexers[requestData.Indexer].UserID
+	}
+}
+
+// checks if at least one of the required artists is credited on the torrent group, in one of the
+// required roles or in any role when none are set. names are compared case-insensitively.
+func hookArtist(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	roles := config.ArtistRoles
+	if len(requestData.RequireArtistRoles) > 0 {
+		roles = requestData.RequireArtistRoles
+	}
+
+	var credited []string
+	for _, role := range roles {
+		for _, credit := range torrentData.Response.Group.MusicInfo.credits(strings.ToLower(strings.TrimSpace(role))) {
+			credited = append(credited, html.UnescapeString(credit.Name))
+		}
+	}
+
+	log.Ctx(ctx).Debug().Msgf("[%s] Credited artists: [%s], Required artists: [%s]", requestData.Indexer, strings.Join(credited, ", "), strings.Join(requestData.RequireArtist, ", "))
+
+	for _, required := range requestData.RequireArtist {
+		for _, name := range credited {
+			if foldString(strings.TrimSpace(name)) == foldString(strings.TrimSpace(required)) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("none of the required artists credited: %s", strings.Join(requestData.RequireArtist, ", "))
+}
+
+// checks the bit depth and sample rate of the torrent. when either is unknown, e.g. for lossy
+// encodings or releases that do not mention their sample rate, allow_unknown_audio decides.
+func hookAudio(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	torrent := torrentData.Response.Torrent
+	bitDepth := parseBitDepth(torrent.Encoding)
+	sampleRate := parseSampleRate(torrent.Media, html.UnescapeString(torrent.ReleaseName), html.UnescapeString(torrent.RemasterTitle))
+
+	log.Ctx(ctx).Debug().Msgf("[%s] Bit depth: %d, Sample rate: %.1f kHz, Requested min bit depth: %d, Allowed sample rates: [%s]", requestData.Indexer, bitDepth, sampleRate, requestData.MinBitDepth, requestData.AllowedSampleRates)
+
+	if requestData.MinBitDepth != 0 {
+		if bitDepth == 0 && !requestData.AllowUnknownAudio {
+			return fmt.Errorf("bit depth of %s is unknown", torrent.Encoding)
+		}
+		if bitDepth != 0 && bitDepth < requestData.MinBitDepth {
+			return fmt.Errorf("bit depth %d is below the minimum bit depth %d", bitDepth, requestData.MinBitDepth)
+		}
+	}
+
+	sampleRates, _ := parseSampleRates(requestData.AllowedSampleRates) // validated with the request
+	if len(sampleRates) == 0 {
+		return nil
+	}
+	if sampleRate == 0 {
+		if requestData.AllowUnknownAudio {
+			return nil
+		}
+		return fmt.Errorf("sample rate is unknown")
+	}
+	for _, allowed := range sampleRates {
+		if math.Abs(allowed-sampleRate) < 0.05 {
+			return nil
+		}
+	}
+	return fmt.Errorf("sample rate %s kHz is not allowed", strconv.FormatFloat(sampleRate, 'f', -1, 64))
+}
+
+// checks the torrent group ID against the explicit allow and block lists. a blocked group is
+// rejected even if it is also allowed.
+func hookGroupID(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	groupID := torrentData.Response.Group.ID
+
+	for _, blocked := range requestData.GroupIDsBlock {
+		if groupID == blocked {
+			log.Ctx(ctx).Debug().Str("indexer", requestData.Indexer).Int("torrent_id", requestData.TorrentID).Int("group_id", groupID).Msg("Rejected by the group ID block list")
+			return fmt.Errorf("torrent group %d is blocked", groupID)
+		}
+	}
+
+	if len(requestData.GroupIDsAllow) == 0 {
+		return nil
+	}
+	for _, allowed := range requestData.GroupIDsAllow {
+		if groupID == allowed {
+			return nil
+		}
+	}
+	log.Ctx(ctx).Debug().Str("indexer", requestData.Indexer).Int("torrent_id", requestData.TorrentID).Int("group_id", groupID).Msg("Rejected, group ID is not in the allow list")
+	return fmt.Errorf("torrent group %d is not in the allow list", groupID)
+}
+
+// checks that no other torrent in the group has a format and encoding ranked higher in the
+// preference than the torrent itself. torrents matching no entry of the preference rank last.
+func hookBestInGroup(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchTorrentWithGroup(ctx, requestData, apiBase)
+	if err != nil {
+		return err
+	}
+
+	preference := parseQualityPreference(requestData.QualityPreference)
+	torrent := torrentData.Response.Torrent
+	rank := qualityRank(preference, torrent.Format, torrent.Encoding)
+
+	log.Ctx(ctx).Trace().Msgf("[%s] Quality: %s %s, rank %d of %d", requestData.Indexer, torrent.Format, torrent.Encoding, rank+1, len(preference))
+
+	for _, sibling := range torrentData.Response.Torrents {
+		if qualityRank(preference, sibling.Format, sibling.Encoding) < rank {
+			log.Ctx(ctx).Debug().Msgf("[%s] Torrent %d in the group is %s %s, which is preferred over %s %s", requestData.Indexer, sibling.ID, sibling.Format, sibling.Encoding, torrent.Format, torrent.Encoding)
+			return fmt.Errorf("group has a better torrent: %s %s", sibling.Format, sibling.Encoding)
+		}
+	}
+
+	return nil
+}
+
+// checks if the encoding of the torrent is in the encoding preference, and that no other torrent in the group
+// has an encoding ranked higher. encodings missing from the preference never outrank one that is listed.
+func hookEncodingPreference(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchTorrentWithGroup(ctx, requestData, apiBase)
+	if err != nil {
+		return err
+	}
+
+	preference := parseQualityPreference(requestData.EncodingPreference)
+	encoding := torrentData.Response.Torrent.Encoding
+	rank := encodingRank(preference, encoding)
+
+	log.Ctx(ctx).Trace().Msgf("[%s] Encoding: %s, rank %d of %d", requestData.Indexer, encoding, rank+1, len(preference))
+
+	if rank == len(preference) {
+		log.Ctx(ctx).Debug().Msgf("[%s] Encoding %s is not in the encoding preference: [%s]", requestData.Indexer, encoding, requestData.EncodingPreference)
+		return fmt.Errorf("encoding %s is not allowed", encoding)
+	}
+
+	for _, sibling := range torrentData.Response.Torrents {
+		if encodingRank(preference, sibling.Encoding) < rank {
+			log.Ctx(ctx).Debug().Msgf("[%s] Torrent %d in the group is %s, which is preferred over %s", requestData.Indexer, sibling.ID, sibling.Encoding, encoding)
+			return fmt.Errorf("group has a preferred encoding: %s", sibling.Encoding)
+		}
+	}
+
+	return nil
+}
+
+// checks if the number of files in the torrent is within the requested range.
+// the file count of the tracker is used, or the entries of the file list when it only sends that.
+func hookFiles(ctx context.Context, requestData *RequestData, apiBase string) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	files := torrentData.Response.Torrent.FileCount
+	if files == 0 {
+		files = countFiles(torrentData.Response.Torrent.FileList)
+	}
+
+	log.Ctx(ctx).Trace().Msgf("[%s] Files: %d, Requested range: %d - %d", requestData.Indexer, files, requestData.MinFiles, requestData.MaxFiles)
+
+	if files == 0 {
+		log.Ctx(ctx).Debug().Msgf("[%s] File count is unknown, skipping the files check", requestData.Indexer)
+		return nil
+	}
+
+	if requestData.MinFiles != 0 && files < requestData.MinFiles {
+		log.Ctx(ctx).Debug().Msgf("[%s] Torrent has %d files, below the minimum %d", requestData.Indexer, files, requestData.MinFiles)
+		return fmt.Errorf("torrent has %d files, below the minimum files %d", files, requestData.MinFiles)
+	}
+
+	if requestData.MaxFiles != 0 && files > requestData.MaxFiles {
+		log.Ctx(ctx).Debug().Msgf("[%s] Torrent has %d files, above the maximum %d", requestData.Indexer, files, requestData.MaxFiles)
+		return fmt.Errorf("torrent has %d files, above the maximum files %d", files, requestData.MaxFiles)
+	}
+
+	return nil
 }
 
 // checks if the user ratio is above the minimum requirement based on the requestData.
-func hookRatio(requestData *RequestData, apiBase string) error {
-	userID := requestData.REDUserID
+func hookRatio(ctx context.Context, requestData *RequestData, apiBase string) error {
+	userID := getUserID(requestData)
 	minRatio := requestData.MinRatio
-	if requestData.Indexer == "ops" {
-		userID = requestData.OPSUserID
-	}
 
 	// Check for incomplete configuration
 	if userID == 0 || minRatio == 0 {
 		if userID != 0 || minRatio != 0 {
-			log.Warn().Msgf("[%s] Incomplete ratio check configuration: userID or minRatio is missing.", requestData.Indexer)
+			log.Ctx(ctx).Warn().Msgf("[%s] Incomplete ratio check configuration: userID or minRatio is missing.", requestData.Indexer)
 		}
 		return nil // Exit early if either is zero, as the check cannot proceed
 	}
 
-	userData, err := fetchResponseData(requestData, userID, "user", apiBase)
+	userData, err := fetchResponseData(ctx, requestData, userID, "user", apiBase)
 	if err != nil {
 		return err
 	}
@@ -116,12 +825,71 @@ func hookRatio(requestData *RequestData, apiBase string) error {
 	ratio := userData.Response.Stats.Ratio
 	username := userData.Response.Username
 
-	log.Trace().Msgf("[%s] MinRatio set to %.2f for %s", requestData.Indexer, minRatio, username)
+	log.Ctx(ctx).Trace().Msgf("[%s] MinRatio set to %.2f for %s", requestData.Indexer, minRatio, username)
 
 	if ratio < minRatio {
-		log.Debug().Msgf("[%s] Returned ratio %.2f is below minratio %.2f for %s", requestData.Indexer, ratio, minRatio, username)
-		return fmt.Errorf("returned ratio is below minimum requirement")
+		log.Ctx(ctx).Debug().Msgf("[%s] Returned ratio %.2f is below minratio %.2f for %s", requestData.Indexer, ratio, minRatio, username)
+		return fmt.Errorf("ratio %.2f is below the minimum ratio %.2f", ratio, minRatio)
+	}
+
+	return nil
+}
+
+// checks that downloading the torrent leaves at least min_ratio_buffer before the user drops below their
+// required ratio. freeleech and neutral leech torrents do not count towards download and always pass.
+func hookRatioBuffer(ctx context.Context, requestData *RequestData, apiBase string) error {
+	userID := getUserID(requestData)
+	if userID == 0 {
+		log.Ctx(ctx).Warn().Msgf("[%s] Incomplete ratio buffer check configuration: userID is missing.", requestData.Indexer)
+		return nil
+	}
+
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	torrent := torrentData.Response.Torrent
+	if torrent.FreeTorrent.FreeLeech() || torrent.FreeTorrent.NeutralLeech() {
+		log.Ctx(ctx).Debug().Msgf("[%s] Skipping ratio buffer check, torrent does not count towards download", requestData.Indexer)
+		return nil
+	}
+
+	userData, err := fetchResponseData(ctx, requestData, userID, "user", apiBase)
+	if err != nil {
+		return err
+	}
+
+	stats := userData.Response.Stats
+	requiredRatio := stats.RequiredRatio
+	if requiredRatio <= 0 {
+		requiredRatio = requestData.MinRatio
+	}
+	if requiredRatio <= 0 {
+		log.Ctx(ctx).Debug().Msgf("[%s] Skipping ratio buffer check, no required ratio", requestData.Indexer)
+		return nil
+	}
+
+	remaining := ratioBufferAfter(stats.Uploaded, stats.Downloaded, torrent.Size, requiredRatio)
+	log.Ctx(ctx).Debug().Msgf("[%s] Uploaded: %d, Downloaded: %d, Required ratio: %.2f, Torrent size: %d, Buffer after download: %d, Requested minimum: %s",
+		requestData.Indexer, stats.Uploaded, stats.Downloaded, requiredRatio, torrent.Size, remaining, requestData.MinRatioBuffer)
+
+	if remaining < int64(requestData.MinRatioBuffer) {
+		return fmt.Errorf("ratio buffer after download would be %s, below the minimum %s", formatBuffer(remaining), requestData.MinRatioBuffer)
 	}
 
 	return nil
 }
+
+// returns how much could still be downloaded after the torrent before the ratio drops below requiredRatio.
+func ratioBufferAfter(uploaded, downloaded, size int64, requiredRatio float64) int64 {
+	return int64(float64(uploaded)/requiredRatio) - downloaded - size
+}
+
+// formats a possibly negative ratio buffer.
+func formatBuffer(buffer int64) string {
+	if buffer < 0 {
+		return "-" + bytesize.ByteSize(-buffer).String()
+	}
+	return bytesize.ByteSize(buffer).String()
+}
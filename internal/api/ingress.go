@@ -0,0 +1,116 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/redactedhook/internal/config"
+	"golang.org/x/time/rate"
+)
+
+// client limiters idle for longer than this are dropped once there are more than maxIngressClients of them.
+const (
+	ingressClientIdle = 10 * time.Minute
+	maxIngressClients = 1000
+)
+
+var ingress = &ingressLimiters{clients: make(map[string]*ingressClient)}
+
+// ingressLimiters holds the limiters for incoming requests, one shared by every client and one per client address.
+type ingressLimiters struct {
+	mu           sync.Mutex
+	global       *rate.Limiter
+	globalLimit  int
+	clients      map[string]*ingressClient
+	clientsLimit int
+}
+
+type ingressClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// returns a limiter allowing limit requests per minute, with a burst of a full minute.
+func newIngressLimiter(limit int) *rate.Limiter {
+	return rate.NewLimiter(rate.Every(time.Minute/time.Duration(limit)), limit)
+}
+
+// checks if a request from the client is within both the global and its own limit, and returns how long
+// the client has to wait when it is not. a limit of zero disables that check. limiters are recreated
+// whenever the configured limit changes.
+func (l *ingressLimiters) wait(client string, globalLimit, clientLimit int, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if clientLimit > 0 {
+		if clientLimit != l.clientsLimit {
+			l.clients = make(map[string]*ingressClient)
+			l.clientsLimit = clientLimit
+		}
+		if len(l.clients) > maxIngressClients {
+			for address, c := range l.clients {
+				if now.Sub(c.lastSeen) > ingressClientIdle {
+					delete(l.clients, address)
+				}
+			}
+		}
+		c, ok := l.clients[client]
+		if !ok {
+			c = &ingressClient{limiter: newIngressLimiter(clientLimit)}
+			l.clients[client] = c
+		}
+		c.lastSeen = now
+		if delay := takeToken(c.limiter, now); delay > 0 {
+			return delay
+		}
+	}
+
+	if globalLimit > 0 {
+		if globalLimit != l.globalLimit {
+			l.global = newIngressLimiter(globalLimit)
+			l.globalLimit = globalLimit
+		}
+		if delay := takeToken(l.global, now); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// takes a token from the limiter if one is available, or returns how long until the next one is.
+func takeToken(limiter *rate.Limiter, now time.Time) time.Duration {
+	reservation := limiter.ReserveN(now, 1)
+	delay := reservation.DelayFrom(now)
+	if delay > 0 {
+		reservation.CancelAt(now)
+	}
+	return delay
+}
+
+// LimitIngressRate wraps a handler so clients sending more requests than client_rate_limit, or all clients
+// together more than global_rate_limit, get a 429 before anything is evaluated. both are off by default.
+func LimitIngressRate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serverCfg := config.GetConfig().Server
+		if serverCfg.ClientRateLimit <= 0 && serverCfg.GlobalRateLimit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// an invalid trusted_proxies entry is reported by RequireAllowedIP, here it only means X-Forwarded-For is not followed
+		trusted, _ := config.ParseNetworks(config.GetConfig().Authorization.TrustedProxies)
+		client := clientIP(r, trusted).String()
+
+		if delay := ingress.wait(client, serverCfg.GlobalRateLimit, serverCfg.ClientRateLimit, time.Now()); delay > 0 {
+			log.Warn().Str("remote_addr", r.RemoteAddr).Str("client_ip", client).Msgf("Rejected %s over the ingress rate limit", r.URL.Path)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
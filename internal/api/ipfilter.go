@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/redactedhook/internal/config"
+)
+
+// RequireAllowedIP wraps a handler so only clients within allowed_ips reach it, others get a 403.
+// an empty allowed_ips lets every client through.
+func RequireAllowedIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authCfg := config.GetConfig().Authorization
+		if len(authCfg.AllowedIPs) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// both lists are validated on startup, a bad entry from a reload blocks everyone until it is fixed
+		allowed, err := config.ParseNetworks(authCfg.AllowedIPs)
+		if err != nil {
+			log.Error().Err(err).Msg("Invalid allowed_ips entry, rejecting every request")
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		trusted, err := config.ParseNetworks(authCfg.TrustedProxies)
+		if err != nil {
+			log.Error().Err(err).Msg("Invalid trusted_proxies entry, rejecting every request")
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		ip := clientIP(r, trusted)
+		if ip == nil || !containsIP(allowed, ip) {
+			log.Warn().Str("remote_addr", r.RemoteAddr).Str("client_ip", ip.String()).Msgf("Rejected %s from an address outside allowed_ips", r.URL.Path)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// returns the address of the client. X-Forwarded-For is only followed while the connection comes from
+// a trusted proxy, from the right, so a client cannot spoof its address by sending the header itself.
+// connections over a Unix socket have no address and are treated as coming from a trusted proxy.
+func clientIP(r *http.Request, trusted []*net.IPNet) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && !containsIP(trusted, ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !containsIP(trusted, hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// checks if the address is within any of the networks.
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
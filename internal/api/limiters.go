@@ -1,31 +1,81 @@
 package api
 
 import (
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/redactedhook/internal/config"
 	"golang.org/x/time/rate"
 )
 
-var (
-	redactedLimiter *rate.Limiter
-	orpheusLimiter  *rate.Limiter
-)
+// the trackers publish their API limits as requests per 10 seconds.
+const rateLimitWindow = 10 * time.Second
 
-func init() {
-	redactedLimiter = rate.NewLimiter(rate.Every(10*time.Second), 10)
-	orpheusLimiter = rate.NewLimiter(rate.Every(10*time.Second), 5)
+var defaultRateLimits = map[string]int{
+	"redacted": 10,
+	"ops":      5,
 }
 
+// used by custom indexer profiles that do not set a rate_limit.
+const defaultProfileRateLimit = 5
+
+var (
+	limiters   = make(map[string]*rate.Limiter)
+	limitersMu sync.Mutex
+)
+
 // returns a rate limiter based on the provided indexer string.
+// limiters are created on first use and retuned whenever the configured rate or burst changes,
+// or, with adaptive_latency set, when the rate is lowered or raised following the API response times.
 func getLimiter(indexer string) *rate.Limiter {
-	switch indexer {
-	case "redacted":
-		return redactedLimiter
-	case "ops":
-		return orpheusLimiter
-	default:
-		log.Error().Msgf("Invalid indexer: %s", indexer)
-		return nil
+	indexerCfg, configured := config.GetConfig().Indexers[indexer]
+	defaultRateLimit, ok := defaultRateLimits[indexer]
+	if !ok {
+		if !configured {
+			log.Error().Msgf("Invalid indexer: %s", indexer)
+			return nil
+		}
+		defaultRateLimit = defaultProfileRateLimit
+	}
+
+	rateLimit := indexerCfg.RateLimit
+	if rateLimit <= 0 {
+		rateLimit = defaultRateLimit
+	}
+	if indexerCfg.AdaptiveLatency > 0 {
+		minRateLimit := indexerCfg.AdaptiveMinRateLimit
+		if minRateLimit <= 0 || minRateLimit > rateLimit {
+			minRateLimit = 1
+		}
+		target := time.Duration(indexerCfg.AdaptiveLatency) * time.Millisecond
+		rateLimit = getLatencyTracker(indexer).rateLimit(indexer, rateLimit, minRateLimit, target, time.Now())
+	}
+	burst := indexerCfg.Burst
+	if burst <= 0 {
+		burst = rateLimit
+	}
+	if indexerCfg.SmoothBurst {
+		// an idle limiter fills up to its full burst, which is then spent at once when traffic resumes.
+		// a burst of one spaces every request by the rate instead, at the cost of never sending back to back
+		burst = 1
+	}
+	limit := rate.Limit(float64(rateLimit) / rateLimitWindow.Seconds())
+
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	limiter, ok := limiters[indexer]
+	if !ok {
+		limiter = rate.NewLimiter(limit, burst)
+		limiters[indexer] = limiter
+		return limiter
+	}
+
+	if limiter.Limit() != limit || limiter.Burst() != burst {
+		log.Debug().Msgf("[%s] Updating rate limit to %d requests per %s (burst %d)", indexer, rateLimit, rateLimitWindow, burst)
+		limiter.SetLimit(limit)
+		limiter.SetBurst(burst)
 	}
+	return limiter
 }
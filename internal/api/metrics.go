@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "redactedhook"
+
+var (
+	apiRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_requests_total",
+		Help:      "Total number of API requests sent to the indexers.",
+	}, []string{"indexer", "action"})
+
+	apiRequestFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_request_failures_total",
+		Help:      "Total number of failed API requests, by failure reason.",
+	}, []string{"indexer", "action", "reason"})
+
+	apiRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "Latency of API requests to the indexers, including retries.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"indexer", "action"})
+
+	apiConnectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_connections_total",
+		Help:      "Total number of connections used for API requests, by protocol and whether the connection was reused.",
+	}, []string{"indexer", "protocol", "reused"})
+
+	cacheLookupsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_lookups_total",
+		Help:      "Total number of response cache lookups, by result (hit or miss).",
+	}, []string{"indexer", "action", "result"})
+
+	cacheBackendErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_backend_errors_total",
+		Help:      "Total number of errors from the cache backend, by operation (load or persist).",
+	}, []string{"operation"})
+
+	circuitBreakerOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "circuit_breaker_open",
+		Help:      "Whether the circuit breaker for an indexer is open (1) or not (0).",
+	}, []string{"indexer"})
+
+	cacheEntries = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "cache_entries",
+		Help:      "Number of responses currently held in the cache.",
+	}, func() float64 {
+		return float64(cache.len())
+	})
+)
+
+// records the outcome of a response cache lookup.
+func recordCacheLookup(indexer, action string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookupsTotal.WithLabelValues(indexer, action, result).Inc()
+}
+
+// maps an API request error to a short, low-cardinality reason label.
+func failureReason(err error) string {
+	var timeoutErr *TimeoutError
+	var retryAfterErr *RetryAfterError
+	var circuitOpenErr *CircuitOpenError
+	var rateLimitErr *RateLimitError
+	var nonJSONErr *NonJSONResponseError
+	var tooLargeErr *ResponseTooLargeError
+	var decompressionErr *DecompressionError
+	var apiErr *APIError
+	var statusErr *HTTPStatusError
+	var invalidJSONErr *InvalidJSONError
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.As(err, &timeoutErr):
+		return "timeout"
+	case errors.As(err, &retryAfterErr):
+		return "tracker_rate_limited"
+	case errors.As(err, &circuitOpenErr):
+		return "circuit_open"
+	case errors.As(err, &rateLimitErr):
+		return "rate_limited"
+	case errors.As(err, &statusErr):
+		return "http_error"
+	case errors.As(err, &nonJSONErr):
+		return "non_json"
+	case errors.As(err, &tooLargeErr):
+		return "too_large"
+	case errors.As(err, &decompressionErr):
+		return "decompression"
+	case errors.As(err, &invalidJSONErr):
+		return "invalid_json"
+	case errors.As(err, &apiErr):
+		return "api_error"
+	default:
+		return "network"
+	}
+}
@@ -1,141 +1,551 @@
 package api
 
 import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"html"
 	"io"
+	"math/rand"
 	"net/http"
+	"net/http/httptrace"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/redactedhook/internal/config"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
 	"golang.org/x/time/rate"
 )
 
 // sends an HTTP GET request to an endpoint with an API key, applies a rate limiter, and unmarshals the response JSON into a target object.
-func makeRequest(endpoint, apiKey string, limiter *rate.Limiter, indexer string, target interface{}) error {
+// transient failures (connection errors, timeouts, HTTP 5xx and 429) are retried with exponential backoff,
+// and count towards the indexer's circuit breaker once all attempts are used up.
+// while max_concurrent_requests calls are already in flight, it waits for one of them to finish.
+func makeRequest(ctx context.Context, endpoint, apiKey string, limiter *rate.Limiter, indexer string, target interface{}) error {
+	if sem := getRequestSemaphore(); sem != nil {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			return err
+		}
+		defer sem.Release(1)
+	}
+
+	maxAttempts, baseDelay := getRetryPolicy()
+	threshold, cooldown := getBreakerPolicy(indexer)
+
+	breaker := getBreaker(indexer)
+	if err := breaker.allow(time.Now(), threshold, cooldown); err != nil {
+		log.Ctx(ctx).Warn().Msgf("%s: Skipping request, %v", indexer, err)
+		return err
+	}
+
+	var err error
+	var retryable bool
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			delay := backoffDelay(baseDelay, attempt-1)
+			log.Ctx(ctx).Debug().Msgf("%s: Retrying request (attempt %d of %d) in %s", indexer, attempt, maxAttempts, delay)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				breaker.release()
+				return ctx.Err()
+			}
+		}
+
+		if retryAfter, ok := reserveRequest(limiter); !ok {
+			log.Ctx(ctx).Warn().Msgf("%s: Too many requests, next request allowed in %s", indexer, retryAfter)
+			if attempt == 1 {
+				breaker.release()
+			} else {
+				breaker.record(retryable, time.Now(), threshold)
+			}
+			return &RateLimitError{Indexer: indexer, RetryAfter: retryAfter}
+		}
+
+		start := time.Now()
+		retryable, err = sendRequest(ctx, endpoint, apiKey, indexer, target)
+		if ctx.Err() == nil {
+			getLatencyTracker(indexer).record(time.Since(start))
+		}
+		if err == nil || !retryable {
+			break
+		}
+	}
+
+	if ctx.Err() != nil {
+		breaker.release() // a cancelled call says nothing about the health of the API
+		return err
+	}
+
+	breaker.record(err != nil && retryable, time.Now(), threshold)
+	return err
+}
 
-	if !limiter.Allow() {
-		log.Warn().Msgf("%s: Too many requests", indexer)
-		return fmt.Errorf("rate limit exceeded for %s", indexer)
+// takes a token from the limiter if one is available right now. otherwise the reservation is
+// given back and the delay until the next token is returned, so callers can be told when to retry.
+func reserveRequest(limiter *rate.Limiter) (time.Duration, bool) {
+	reservation := limiter.Reserve()
+	if !reservation.OK() {
+		return rateLimitWindow, false
 	}
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return delay, false
+	}
+	return 0, true
+}
 
+// performs a single request attempt and reports whether a failure is worth retrying.
+func sendRequest(ctx context.Context, endpoint, apiKey string, indexer string, target interface{}) (bool, error) {
 	req, err := http.NewRequest("GET", endpoint, nil)
 	if err != nil {
-		log.Error().Err(err).Msg("Error creating HTTP request")
-		return err
+		log.Ctx(ctx).Error().Err(err).Msg("Error creating HTTP request")
+		return false, err
 	}
-	req.Header.Set("Authorization", apiKey)
+	authHeader, authValue := determineAuthHeader(indexer, apiKey)
+	req.Header.Set(authHeader, authValue)
+	req.Header.Set("User-Agent", userAgent())
+	// setting this ourselves turns off the transport's transparent gzip handling, so the body is decoded below
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// the timeout is derived from the webhook request, so a client that goes away cancels the call too
+	timeout := getRequestTimeout(indexer)
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	req = req.WithContext(ctx)
+	var reused bool
+	trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused }}
+	req = req.WithContext(httptrace.WithClientTrace(timeoutCtx, trace))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := getHTTPClient().Do(req)
 	if err != nil {
-		log.Error().Err(err).Msg("Error executing HTTP request")
-		return err
+		if ctx.Err() != nil {
+			log.Ctx(ctx).Debug().Msgf("%s: Request cancelled by the client", indexer)
+			return false, ctx.Err()
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Ctx(ctx).Error().Msgf("%s: Request timed out after %s", indexer, timeout)
+			return true, &TimeoutError{Indexer: indexer, Timeout: timeout}
+		}
+		log.Ctx(ctx).Error().Err(err).Msg("Error executing HTTP request")
+		return true, err
 	}
-	defer resp.Body.Close()
+	apiConnectionsTotal.WithLabelValues(indexer, resp.Proto, strconv.FormatBool(reused)).Inc()
+	log.Ctx(ctx).Trace().Msgf("%s: Response over %s, reused connection: %t", indexer, resp.Proto, reused)
+	defer func() {
+		// drain whatever is left so the connection can be reused
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
 
 	//dump, err := httputil.DumpResponse(resp, true)
 	//if err != nil {
-	//	log.Error().Err(err).Msg("Error dumping the response")
+	//	log.Ctx(ctx).Error().Err(err).Msg("Error dumping the response")
 	//	return err
 	//}
 	//
 	//fmt.Printf("HTTP Response:\n%s\n", dump)
 
-	if resp.StatusCode >= 400 {
-		errMsg := fmt.Sprintf("HTTP error: %d from %s", resp.StatusCode, endpoint)
-		log.Error().Msg(errMsg)
-		return errors.New(errMsg)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			log.Ctx(ctx).Warn().Msgf("%s: Rate limited by tracker, retry after %s", indexer, retryAfter)
+			return false, &RetryAfterError{Indexer: indexer, RetryAfter: retryAfter}
+		}
 	}
 
-	respBody, err := io.ReadAll(resp.Body)
+	maxBodySize := getMaxBodySize()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
 	if err != nil {
-		log.Error().Err(err).Msg("fetchAPI error")
-		return err
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Ctx(ctx).Error().Msgf("%s: Request timed out after %s", indexer, timeout)
+			return true, &TimeoutError{Indexer: indexer, Timeout: timeout}
+		}
+		log.Ctx(ctx).Error().Err(err).Msg("fetchAPI error")
+		return true, err
+	}
+
+	if int64(len(respBody)) <= maxBodySize {
+		if respBody, err = decodeBody(respBody, resp.Header.Get("Content-Encoding"), maxBodySize); err != nil {
+			decodeErr := &DecompressionError{Indexer: indexer, Encoding: resp.Header.Get("Content-Encoding"), Err: err}
+			log.Ctx(ctx).Error().Msgf("%v", decodeErr)
+			return false, decodeErr
+		}
+	}
+
+	if int64(len(respBody)) > maxBodySize {
+		tooLargeErr := &ResponseTooLargeError{Indexer: indexer, Limit: maxBodySize}
+		log.Ctx(ctx).Error().Msgf("%v", tooLargeErr)
+		return false, tooLargeErr
+	}
+
+	// block and challenge pages come back as HTML, often with a 403 or 503, and would otherwise
+	// only show up as a cryptic JSON syntax error
+	if !looksLikeJSON(respBody) {
+		nonJSONErr := &NonJSONResponseError{
+			Indexer:     indexer,
+			StatusCode:  resp.StatusCode,
+			ContentType: resp.Header.Get("Content-Type"),
+			Cloudflare:  isCloudflarePage(resp.Header, respBody),
+		}
+		log.Ctx(ctx).Error().Str("content_type", nonJSONErr.ContentType).Msgf("%s: %v", indexer, nonJSONErr)
+		return resp.StatusCode >= 500, nonJSONErr
+	}
+
+	// some gazelle versions answer a bad ID with a 400 and the usual failure body
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		var failure struct {
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		}
+		if json.Unmarshal(respBody, &failure) == nil && failure.Status == "failure" && failure.Error != "" {
+			return false, &APIError{Indexer: indexer, Message: failure.Error}
+		}
+	}
+
+	if resp.StatusCode >= 400 {
+		statusErr := &HTTPStatusError{Indexer: indexer, StatusCode: resp.StatusCode, Endpoint: endpoint}
+		log.Ctx(ctx).Error().Msg(statusErr.Error())
+		return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, statusErr
 	}
 
 	if err := json.Unmarshal(respBody, target); err != nil {
-		log.Error().Err(err).Msg("Invalid JSON response")
-		return fmt.Errorf("invalid JSON response: %w", err)
+		log.Ctx(ctx).Error().Err(err).Msg("Invalid JSON response")
+		return false, &InvalidJSONError{Indexer: indexer, Err: err}
+	}
+
+	if config.GetConfig().Logs.LogAPIResponses {
+		log.Ctx(ctx).Trace().Msgf("%s: API response from %s:\n%s", indexer, endpoint, redactResponse(respBody, apiKey))
 	}
 
 	responseData, ok := target.(*ResponseData)
 	if !ok {
-		log.Error().Msg("Invalid target type for JSON unmarshalling")
-		return fmt.Errorf("invalid target type")
+		log.Ctx(ctx).Error().Msg("Invalid target type for JSON unmarshalling")
+		return false, fmt.Errorf("invalid target type")
 	}
 
 	if responseData.Status != "success" {
-		//	log.Warn().Msgf("API error from %s: %s", indexer, responseData.Error)
-		return fmt.Errorf("API error from %s: %s", indexer, responseData.Error)
+		//	log.Ctx(ctx).Warn().Msgf("API error from %s: %s", indexer, responseData.Error)
+		return false, &APIError{Indexer: indexer, Message: responseData.Error}
+	}
+
+	return false, nil
+}
+
+// decompresses a gzip or deflate encoded body, reading at most maxBodySize+1 bytes of the decoded
+// stream so the size limit also holds for what the body expands to. other bodies are returned as is.
+func decodeBody(body []byte, contentEncoding string, maxBodySize int64) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		if reader, err = gzip.NewReader(bytes.NewReader(body)); err != nil {
+			return nil, err
+		}
+	case "deflate":
+		// deflate is meant to be zlib wrapped, but some servers send the raw stream
+		if reader, err = zlib.NewReader(bytes.NewReader(body)); err != nil {
+			reader = flate.NewReader(bytes.NewReader(body))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", contentEncoding)
+	}
+	defer reader.Close()
+
+	return io.ReadAll(io.LimitReader(reader, maxBodySize+1))
+}
+
+// checks if the body starts like a JSON object or array.
+func looksLikeJSON(body []byte) bool {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+}
+
+// checks if a non-JSON response was most likely served by Cloudflare instead of the tracker.
+func isCloudflarePage(header http.Header, body []byte) bool {
+	if header.Get("CF-RAY") != "" || strings.EqualFold(header.Get("Server"), "cloudflare") {
+		return true
+	}
+	if len(body) > 4096 {
+		body = body[:4096]
+	}
+	return bytes.Contains(bytes.ToLower(body), []byte("cloudflare"))
+}
+
+// returns the delay before the given retry, doubling the base delay each time and adding jitter.
+func backoffDelay(baseDelay time.Duration, retry int) time.Duration {
+	delay := baseDelay << (retry - 1)
+	if delay <= 0 {
+		return 0
 	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay)))
+}
 
-	return nil
+// returns the longest a call to the indexer can take with every attempt the retry policy allows:
+// the request timeout for each attempt, plus the longest backoff before each retry.
+func fetchTimeout(indexer string) time.Duration {
+	maxAttempts, baseDelay := getRetryPolicy()
+	timeout := getRequestTimeout(indexer) * time.Duration(maxAttempts)
+	for retry := 1; retry < maxAttempts; retry++ {
+		delay := baseDelay << (retry - 1)
+		timeout += delay/2 + delay
+	}
+	return timeout
 }
 
 // initiates an API request with the given parameters and returns the response data or an error.
-func initiateAPIRequest(id int, action string, apiKey, apiBase, indexer string) (*ResponseData, error) {
+// when hash is set the torrent is looked up by its infohash instead of the ID.
+func initiateAPIRequest(ctx context.Context, id int, hash string, action string, apiKey, apiBase, indexer string) (*ResponseData, error) {
 	limiter := getLimiter(indexer)
 	if limiter == nil {
 		return nil, fmt.Errorf("could not get rate limiter for indexer: %s", indexer)
 	}
 
 	endpoint := fmt.Sprintf("%s?action=%s&id=%d", apiBase, action, id)
-	responseData := &ResponseData{}
-	err := makeRequest(endpoint, apiKey, limiter, indexer, responseData)
+	if hash != "" {
+		endpoint = fmt.Sprintf("%s?action=%s&hash=%s", apiBase, action, hash)
+	}
+	responseData := &ResponseData{action: action}
+
+	apiRequestsTotal.WithLabelValues(indexer, action).Inc()
+	start := time.Now()
+	err := makeRequest(ctx, endpoint, apiKey, limiter, indexer, responseData)
+	apiRequestDuration.WithLabelValues(indexer, action).Observe(time.Since(start).Seconds())
 	if err != nil {
+		apiRequestFailuresTotal.WithLabelValues(indexer, action, failureReason(err)).Inc()
 		return nil, err
 	}
 
 	if action == "torrent" && responseData.Response.Torrent != nil {
 		releaseName := html.UnescapeString(responseData.Response.Torrent.ReleaseName)
 		uploader := responseData.Response.Torrent.Username
-		log.Debug().Msgf("[%s] Checking release: %s - (Uploader: %s) (TorrentID: %d)", indexer, releaseName, uploader, id)
+		log.Ctx(ctx).Debug().Str("indexer", indexer).Str("action", action).Int("torrent_id", id).Str("release_name", releaseName).Str("uploader", uploader).
+			Int("seeders", responseData.Response.Torrent.Seeders).Int("leechers", responseData.Response.Torrent.Leechers).Msg("Checking release")
+	}
+
+	if action == "torrentgroup" {
+		groupName := html.UnescapeString(responseData.Response.Group.Name)
+		torrentCount := len(responseData.Response.Torrents)
+		log.Ctx(ctx).Debug().Str("indexer", indexer).Str("action", action).Int("group_id", id).Str("group_name", groupName).Int("torrents", torrentCount).Msg("Fetched torrent group")
+	}
+
+	if action == "collage" && responseData.Response.Collage != nil {
+		collageName := html.UnescapeString(responseData.Response.Collage.Name)
+		groupCount := len(responseData.Response.Collage.TorrentGroupIDList)
+		log.Ctx(ctx).Debug().Str("indexer", indexer).Str("action", action).Int("collage_id", id).Str("collage_name", collageName).Int("groups", groupCount).Msg("Fetched collage")
+	}
+
+	if action == "artist" && responseData.Response.Artist != nil {
+		artistName := html.UnescapeString(responseData.Response.Artist.Name)
+		groupCount := len(responseData.Response.Artist.TorrentGroup)
+		log.Ctx(ctx).Debug().Str("indexer", indexer).Str("action", action).Int("artist_id", id).Str("artist_name", artistName).Int("groups", groupCount).Msg("Fetched artist")
 	}
 
 	return responseData, nil
 }
 
-// fetches response data from an API, checks the cache first, and caches the response data for future use.
-func fetchResponseData(requestData *RequestData, id int, action string, apiBase string) (*ResponseData, error) {
+// deduplicates API calls for the same cache key that are in flight at the same time.
+var inflightFetches singleflight.Group
 
-	// Check cache first
-	cacheKey := fmt.Sprintf("%sID %d", action, id)
-	cachedData, found := checkCache(cacheKey, requestData.Indexer)
-	if found {
-		return cachedData, nil
-	}
+// detachedContext keeps the values of its parent, such as the request logger, but not its cancellation
+// or deadline, for work shared with other requests. it stands in for context.WithoutCancel from go 1.21.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
 
+// fetches response data from an API, checks the cache first, and caches the response data for future use.
+func fetchResponseData(ctx context.Context, requestData *RequestData, id int, action string, apiBase string) (*ResponseData, error) {
 	apiKey, err := getAPIKey(requestData)
 	if err != nil {
 		return nil, err
 	}
 
-	responseData, err := initiateAPIRequest(id, action, apiKey, apiBase, requestData.Indexer)
-	if err != nil {
-		if strings.Contains(err.Error(), "rate limit exceeded") {
-			return nil, err
+	// without a torrent ID the torrent is looked up, and cached, by its infohash
+	var hash string
+	ref := fmt.Sprintf("ID %d", id)
+	cacheKey := responseCacheKey(requestData.Indexer, apiKey, action, id)
+	if action == "torrent" && id == 0 && requestData.TorrentHash != "" {
+		hash = strings.ToUpper(requestData.TorrentHash)
+		ref = "hash " + hash
+		cacheKey = torrentHashCacheKey(requestData.Indexer, apiKey, hash)
+
+		// with resolve_hashes set, a hash seen before is looked up by the ID it belongs to
+		if config.GetConfig().Cache.ResolveHashes {
+			if resolvedID, ok := resolveHash(cacheKey); ok {
+				log.Ctx(ctx).Trace().Msgf("[%s] Resolved hash %s to torrent ID %d", requestData.Indexer, hash, resolvedID)
+				id, hash = resolvedID, ""
+				ref = fmt.Sprintf("ID %d", id)
+				cacheKey = responseCacheKey(requestData.Indexer, apiKey, action, id)
+			}
 		}
-		wrappedErr := fmt.Errorf("error fetching %s data for ID %d: %w", action, id, err)
-		log.Error().Err(wrappedErr).Msg("Data fetching")
-		return nil, wrappedErr
 	}
 
-	// Cache the response data
-	cacheResponseData(cacheKey, responseData)
+	// Check cache first, unless the request asks for fresh data. the response is cached either way
+	if !requestData.NoCache {
+		cacheStart := time.Now()
+		if err := checkFailureCache(ctx, cacheKey, requestData.Indexer); err != nil {
+			addTiming(ctx, "cache", cacheStart)
+			return nil, fmt.Errorf("error fetching %s data for %s: %w", action, ref, err)
+		}
+		cachedData, found := checkCache(ctx, cacheKey, requestData.Indexer)
+		recordCacheLookup(requestData.Indexer, action, found)
+		addTiming(ctx, "cache", cacheStart)
+		if found {
+			recordTorrent(ctx, action, cachedData)
+			return cachedData, nil
+		}
+	}
+
+	// concurrent requests for the same uncached response share a single API call. the call is detached
+	// from the request that started it, so a client going away does not fail the others waiting for it,
+	// and is bounded by the time all attempts and the backoff between them can take instead. each caller only waits as long
+	// as its own context allows
+	fetchStart := time.Now()
+	fetch := inflightFetches.DoChan(cacheKey, func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(detachedContext{ctx}, fetchTimeout(requestData.Indexer))
+		defer cancel()
+
+		responseData, err := initiateAPIRequest(fetchCtx, id, hash, action, apiKey, apiBase, requestData.Indexer)
+		if err != nil {
+			var rateLimitErr *RateLimitError
+			var circuitOpenErr *CircuitOpenError
+			if errors.As(err, &rateLimitErr) || errors.As(err, &circuitOpenErr) {
+				return nil, err
+			}
+			wrappedErr := fmt.Errorf("error fetching %s data for %s: %w", action, ref, err)
+			log.Ctx(ctx).Error().Err(wrappedErr).Msg("Data fetching")
+			var apiErr *APIError
+			if errors.As(err, &apiErr) {
+				cacheFailure(cacheKey, requestData.Indexer, action, id, apiErr)
+				return nil, wrappedErr
+			}
+			return nil, &UnreachableError{Err: wrappedErr}
+		}
+
+		// Cache the response data
+		storeKey, storeID := cacheKey, id
+		if action == "torrent" && config.GetConfig().Cache.ResolveHashes {
+			storeKey, storeID = resolveTorrentCacheKey(requestData.Indexer, apiKey, cacheKey, id, hash, responseData)
+		}
+		cacheResponseData(storeKey, requestData.Indexer, action, storeID, responseData)
+
+		return responseData, nil
+	})
 
+	var result singleflight.Result
+	select {
+	case result = <-fetch:
+	case <-ctx.Done():
+		addTiming(ctx, "fetch", fetchStart)
+		log.Ctx(ctx).Debug().Msgf("[%s] Stopped waiting for the %s request for %s, request cancelled by the client", requestData.Indexer, action, ref)
+		return nil, ctx.Err()
+	}
+	addTiming(ctx, "fetch", fetchStart)
+	if result.Shared {
+		log.Ctx(ctx).Trace().Msgf("[%s] Shared the in-flight %s request for %s", requestData.Indexer, action, ref)
+	}
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	responseData := result.Val.(*ResponseData)
+	recordTorrent(ctx, action, responseData)
 	return responseData, nil
 }
 
-// determines the API base endpoint based on the provided indexer.
+// returns the key and ID a torrent response is cached under with resolve_hashes set. a torrent fetched by
+// infohash is cached under its ID, and the hash is remembered, so later lookups by either hash or ID share
+// one entry. for a torrent fetched by ID the infohash from the response is remembered.
+func resolveTorrentCacheKey(indexer, apiKey, cacheKey string, id int, hash string, responseData *ResponseData) (string, int) {
+	torrent := responseData.Response.Torrent
+	if torrent == nil || torrent.ID == 0 {
+		return cacheKey, id
+	}
+
+	if hash == "" {
+		if torrent.InfoHash != "" {
+			rememberHash(torrentHashCacheKey(indexer, apiKey, strings.ToUpper(torrent.InfoHash)), torrent.ID)
+		}
+		return cacheKey, id
+	}
+
+	rememberHash(cacheKey, torrent.ID)
+	return responseCacheKey(indexer, apiKey, "torrent", torrent.ID), torrent.ID
+}
+
+// fetches the torrent and its torrent group, and returns the torrent response with the group data merged in.
+// when the payload carries the group ID both are fetched concurrently, otherwise the group ID is taken from
+// the torrent response first. each response is cached under its own key.
+func fetchTorrentWithGroup(ctx context.Context, requestData *RequestData, apiBase string) (*ResponseData, error) {
+	var torrentData, groupData *ResponseData
+
+	if requestData.GroupID == 0 {
+		var err error
+		if torrentData, err = fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase); err != nil {
+			return nil, err
+		}
+		if groupData, err = fetchResponseData(ctx, requestData, torrentData.Response.Group.ID, "torrentgroup", apiBase); err != nil {
+			return nil, err
+		}
+	} else {
+		var g errgroup.Group
+		g.Go(func() (err error) {
+			torrentData, err = fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+			return err
+		})
+		g.Go(func() (err error) {
+			groupData, err = fetchResponseData(ctx, requestData, requestData.GroupID, "torrentgroup", apiBase)
+			return err
+		})
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+	}
+
+	// copy so the cached torrent response is left untouched
+	merged := *torrentData
+	merged.Response.Group = groupData.Response.Group
+	merged.Response.Torrents = groupData.Response.Torrents
+	return &merged, nil
+}
+
+// determines the header and value the API key is sent as for the indexer.
+// both trackers take the bare key in Authorization, which stays the default.
+func determineAuthHeader(indexer, apiKey string) (string, string) {
+	indexerCfg := config.GetConfig().Indexers[indexer]
+
+	header := indexerCfg.AuthHeader
+	if header == "" {
+		header = "Authorization"
+	}
+	if indexerCfg.AuthFormat == "" {
+		return header, apiKey
+	}
+	return header, strings.ReplaceAll(indexerCfg.AuthFormat, "{key}", apiKey)
+}
+
+// determines the API base endpoint based on the provided indexer or custom profile name.
+// a configured api_base for the indexer takes precedence over the built-in endpoint.
 func determineAPIBase(indexer string) (string, error) {
+	if apiBase := config.GetConfig().Indexers[indexer].APIBase; apiBase != "" {
+		return apiBase, nil
+	}
+
 	switch indexer {
 	case "redacted":
 		return APIEndpointBaseRedacted, nil
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/redactedhook/internal/config"
+)
+
+// runs the checks once per configured rule group and approves as soon as one of them passes.
+// the filters within a group must all pass, the groups themselves are alternatives. without
+// any rule groups the request is evaluated as a single group, as before.
+func evaluateRuleGroups(ctx context.Context, requestData *RequestData, apiBase string) (int, error) {
+	ruleGroups := config.GetConfig().RuleGroups
+	if len(ruleGroups) == 0 {
+		return runChecks(ctx, requestData, apiBase)
+	}
+
+	names := make([]string, 0, len(ruleGroups))
+	for name := range ruleGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	statusCode := http.StatusOK
+	var reasons []string
+	for _, name := range names {
+		groupRequest, err := applyRuleGroup(requestData, ruleGroups[name])
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msgf("Skipping invalid rule group %s", name)
+			reasons = append(reasons, name+": "+err.Error())
+			continue
+		}
+
+		startRuleGroup(ctx, name)
+		code, err := runChecks(ctx, groupRequest, apiBase)
+		if err == nil {
+			log.Ctx(ctx).Debug().Msgf("[%s] Rule group %s passed", requestData.Indexer, name)
+			return http.StatusOK, nil
+		}
+		if isTransientError(err) {
+			return code, err // the tracker could not be asked, so no group can be decided either
+		}
+
+		log.Ctx(ctx).Debug().Msgf("[%s] Rule group %s failed: %v", requestData.Indexer, name, err)
+		statusCode = code
+		reasons = append(reasons, name+": "+err.Error())
+	}
+
+	if statusCode == http.StatusOK {
+		statusCode = http.StatusBadRequest // every group was invalid
+	}
+	return statusCode, fmt.Errorf("no rule group passed (%s)", strings.Join(reasons, "; "))
+}
+
+// returns a copy of requestData with the filters of the rule group applied on top. groups use the
+// same field names and formats as the webhook payload; fields a group does not set are kept.
+func applyRuleGroup(requestData *RequestData, ruleGroup map[string]interface{}) (*RequestData, error) {
+	groupRequest := *requestData
+	if err := applyFilters(&groupRequest, ruleGroup); err != nil {
+		return nil, fmt.Errorf("invalid rule group: %w", err)
+	}
+	if err := validateRequestData(&groupRequest); err != nil {
+		return nil, err
+	}
+	return &groupRequest, nil
+}
+
+// sets the payload fields named in filters on requestData, keeping the fields filters does not name.
+// the lists filters set are cleared first, as json.Unmarshal would write into their backing arrays,
+// which may be shared with the config or the request the rule group was copied from.
+func applyFilters(requestData *RequestData, filters map[string]interface{}) error {
+	data, err := json.Marshal(filters)
+	if err != nil {
+		return err
+	}
+
+	value := reflect.ValueOf(requestData).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		name, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("json"), ",")
+		if _, ok := filters[name]; ok && value.Field(i).Kind() == reflect.Slice {
+			value.Field(i).Set(reflect.Zero(value.Field(i).Type()))
+		}
+	}
+	return json.Unmarshal(data, requestData)
+}
+
+// checks if the error comes from not reaching the tracker, rather than from a check that failed.
+func isTransientError(err error) bool {
+	var timeoutErr *TimeoutError
+	var retryAfterErr *RetryAfterError
+	var rateLimitErr *RateLimitError
+	var circuitOpenErr *CircuitOpenError
+	var unreachableErr *UnreachableError
+	return errors.As(err, &timeoutErr) || errors.As(err, &retryAfterErr) || errors.As(err, &rateLimitErr) ||
+		errors.As(err, &circuitOpenErr) || errors.As(err, &unreachableErr) || errors.Is(err, context.Canceled)
+}
+
+// checks if the request should be approved unchecked because the tracker could not be reached
+// and on_api_error is set to approve. failure statuses from the tracker are still rejected.
+func failOpen(err error) bool {
+	return config.GetConfig().Server.OnAPIError == "approve" && isTransientError(err) && !errors.Is(err, context.Canceled)
+}
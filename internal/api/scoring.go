@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/redactedhook/internal/config"
+)
+
+type scoreKey struct{}
+
+// scoreResult holds the score of the torrent last scored with a context, for the score in the verdict.
+type scoreResult struct {
+	mu    sync.Mutex
+	score *int
+}
+
+// returns a context that keeps the score of the torrent evaluated with it.
+func withScore(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scoreKey{}, &scoreResult{})
+}
+
+// stores the score, if the context keeps one.
+func recordScore(ctx context.Context, score int) {
+	result, ok := ctx.Value(scoreKey{}).(*scoreResult)
+	if !ok {
+		return
+	}
+	result.mu.Lock()
+	result.score = &score
+	result.mu.Unlock()
+}
+
+// returns the score kept in the context, or nil when no torrent was scored.
+func scoreFrom(ctx context.Context) *int {
+	result, ok := ctx.Value(scoreKey{}).(*scoreResult)
+	if !ok {
+		return nil
+	}
+	result.mu.Lock()
+	defer result.mu.Unlock()
+	return result.score
+}
+
+// returns the folded values a text field has on the torrent, one per tag for tags.
+func scoreTexts(field string, torrentData *ResponseData) []string {
+	torrent := torrentData.Response.Torrent
+	group := torrentData.Response.Group
+
+	switch field {
+	case "format":
+		return []string{foldString(torrent.Format)}
+	case "encoding":
+		return []string{foldString(torrent.Encoding)}
+	case "media":
+		return []string{foldString(torrent.Media)}
+	case "uploader":
+		return []string{foldString(torrent.Username)}
+	case "record_label":
+		recordLabel := torrent.RecordLabel
+		if recordLabel == "" {
+			recordLabel = group.RecordLabel
+		}
+		return []string{foldString(html.UnescapeString(recordLabel))}
+	case "release_type":
+		return []string{foldString(config.ReleaseTypeName(group.ReleaseType))}
+	case "tag":
+		tags := make([]string, 0, len(group.Tags))
+		for _, tag := range group.Tags {
+			tags = append(tags, normalizeTag(tag))
+		}
+		return tags
+	}
+	return nil
+}
+
+// returns whether a flag field is set on the torrent. flags the tracker does not send count as unset.
+func scoreFlag(field, indexer string, torrentData *ResponseData) bool {
+	torrent := torrentData.Response.Torrent
+
+	switch field {
+	case "scene":
+		return torrent.Scene
+	case "has_log":
+		return torrent.HasLog
+	case "has_cue":
+		return torrent.HasCue
+	case "freeleech":
+		return torrent.FreeTorrent.FreeLeech() || torrent.FreeTorrent.NeutralLeech()
+	case "reported":
+		return torrent.Reported != nil && *torrent.Reported
+	case "trumpable":
+		return torrent.Trumpable != nil && *torrent.Trumpable
+	case "featured":
+		featured, _ := isFeatured(featuredFlag(indexer), torrentData)
+		return featured
+	}
+	return false
+}
+
+// returns the value of a count field on the torrent.
+func scoreCount(field string, torrentData *ResponseData) int {
+	torrent := torrentData.Response.Torrent
+
+	switch field {
+	case "seeders":
+		return torrent.Seeders
+	case "leechers":
+		return torrent.Leechers
+	case "snatched":
+		return torrent.Snatched
+	case "log_score":
+		return torrent.LogScore
+	}
+	return 0
+}
+
+// adds up the points the rules give the torrent, along with a description of every rule that
+// changed the score, for the logs. count fields get the points once for every full per.
+func scoreTorrent(rules []config.ScoreRule, indexer string, torrentData *ResponseData) (int, []string) {
+	score := 0
+	var matched []string
+	for _, rule := range rules {
+		points := 0
+		switch {
+		case contains(config.ScoreTextFields, rule.Field):
+			value := foldString(rule.Value)
+			if rule.Field == "tag" {
+				value = normalizeTag(rule.Value)
+			}
+			if contains(scoreTexts(rule.Field, torrentData), value) {
+				points = rule.Points
+			}
+		case contains(config.ScoreFlagFields, rule.Field):
+			if scoreFlag(rule.Field, indexer, torrentData) {
+				points = rule.Points
+			}
+		case contains(config.ScoreCountFields, rule.Field) && rule.Per > 0:
+			points = scoreCount(rule.Field, torrentData) / rule.Per * rule.Points
+		}
+		if points == 0 {
+			continue
+		}
+
+		score += points
+		switch {
+		case rule.Value != "":
+			matched = append(matched, fmt.Sprintf("%s %s %+d", rule.Field, rule.Value, points))
+		case rule.Per > 0:
+			matched = append(matched, fmt.Sprintf("%s %d %+d", rule.Field, scoreCount(rule.Field, torrentData), points))
+		default:
+			matched = append(matched, fmt.Sprintf("%s %+d", rule.Field, points))
+		}
+	}
+	return score, matched
+}
+
+// checks if the torrent scores at least min_score with the scoring rules. the score is kept in the context,
+// so it can be sent back with the verdict.
+func hookScore(ctx context.Context, requestData *RequestData, apiBase string, rules []config.ScoreRule) error {
+	torrentData, err := fetchResponseData(ctx, requestData, requestData.TorrentID, "torrent", apiBase)
+	if err != nil {
+		return err
+	}
+
+	score, matched := scoreTorrent(rules, requestData.Indexer, torrentData)
+	recordScore(ctx, score)
+
+	log.Ctx(ctx).Debug().Msgf("[%s] Score: %d (%s), MinScore: %d", requestData.Indexer, score, strings.Join(matched, ", "), requestData.MinScore)
+
+	if score < requestData.MinScore {
+		return &ScoreError{Score: score, MinScore: requestData.MinScore}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type serverTimingKey struct{}
+
+// serverTiming adds up how long a webhook request spent in each phase, for the Server-Timing header.
+// API calls can run concurrently, so the phases are only a breakdown of the work and may add up to more than the request took.
+type serverTiming struct {
+	mu        sync.Mutex
+	start     time.Time
+	durations map[string]time.Duration
+}
+
+// returns a context that collects the phase durations of the request evaluated with it.
+func withServerTiming(ctx context.Context) context.Context {
+	return context.WithValue(ctx, serverTimingKey{}, &serverTiming{start: time.Now(), durations: make(map[string]time.Duration)})
+}
+
+// adds the time since start to the phase, if the context collects timings.
+func addTiming(ctx context.Context, phase string, start time.Time) {
+	timing, ok := ctx.Value(serverTimingKey{}).(*serverTiming)
+	if !ok {
+		return
+	}
+	timing.mu.Lock()
+	timing.durations[phase] += time.Since(start)
+	timing.mu.Unlock()
+}
+
+// sets the Server-Timing header from the timings collected in the context. eval is the time spent
+// outside cache lookups and API calls, phases that did not run are left out.
+func setServerTiming(ctx context.Context, header http.Header) {
+	t, ok := ctx.Value(serverTimingKey{}).(*serverTiming)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	eval := time.Since(t.start) - t.durations["cache"] - t.durations["fetch"]
+	if eval < 0 {
+		eval = 0
+	}
+
+	var metrics []string
+	for _, phase := range []string{"cache", "fetch"} {
+		if duration, ok := t.durations[phase]; ok {
+			metrics = append(metrics, formatTiming(phase, duration))
+		}
+	}
+	metrics = append(metrics, formatTiming("eval", eval))
+	header.Set("Server-Timing", strings.Join(metrics, ", "))
+}
+
+// formats a single Server-Timing metric with its duration in milliseconds.
+func formatTiming(phase string, duration time.Duration) string {
+	return fmt.Sprintf("%s;dur=%.2f", phase, float64(duration.Microseconds())/1000)
+}
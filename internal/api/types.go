@@ -1,54 +1,358 @@
 package api
 
 import (
+	"encoding/json"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/inhies/go-bytesize"
+	"github.com/s0up4200/redactedhook/internal/config"
 )
 
 type CacheItem struct {
 	Data        *ResponseData
+	Indexer     string
+	Action      string
+	ID          int
+	APIError    string // set for cached failures, Data is nil then
 	LastFetched time.Time
+	ExpiresAt   time.Time
 }
 
 type RequestData struct {
-	REDUserID   int               `json:"red_user_id,omitempty"`
-	OPSUserID   int               `json:"ops_user_id,omitempty"`
-	TorrentID   int               `json:"torrent_id,omitempty"`
-	REDKey      string            `json:"red_apikey,omitempty"`
-	OPSKey      string            `json:"ops_apikey,omitempty"`
-	MinRatio    float64           `json:"minratio,omitempty"`
-	MinSize     bytesize.ByteSize `json:"minsize,omitempty"`
-	MaxSize     bytesize.ByteSize `json:"maxsize,omitempty"`
-	Uploaders   string            `json:"uploaders,omitempty"`
-	RecordLabel string            `json:"record_labels,omitempty"`
-	Mode        string            `json:"mode,omitempty"`
-	Indexer     string            `json:"indexer"`
+	REDUserID          int      `json:"red_user_id,omitempty"`
+	OPSUserID          int      `json:"ops_user_id,omitempty"`
+	TorrentID          int      `json:"torrent_id,omitempty"`
+	TorrentIDs         []int    `json:"torrent_ids,omitempty"`
+	TorrentHash        string   `json:"torrent_hash,omitempty"`
+	REDKey             string   `json:"red_apikey,omitempty"`
+	OPSKey             string   `json:"ops_apikey,omitempty"`
+	MinRatio           float64  `json:"minratio,omitempty"`
+	MinSize            ByteSize `json:"minsize,omitempty"`
+	MaxSize            ByteSize `json:"maxsize,omitempty"`
+	Uploaders          string   `json:"uploaders,omitempty"`
+	AllowedUploaders   string   `json:"allowed_uploaders,omitempty"`
+	BlockedUploaders   string   `json:"blocked_uploaders,omitempty"`
+	RecordLabel        string   `json:"record_labels,omitempty"`
+	BlockedRecordLabel string   `json:"blocked_record_labels,omitempty"`
+	Mode               string   `json:"mode,omitempty"`
+	MinLogScore        int      `json:"min_log_score,omitempty"`
+	StrictLogScore     bool     `json:"strict_log_score,omitempty"`
+	RequireLog         bool     `json:"require_log,omitempty"`
+	RequireCue         bool     `json:"require_cue,omitempty"`
+	FreeleechOnly      bool     `json:"freeleech_only,omitempty"`
+	SkipFreeleech      bool     `json:"skip_freeleech,omitempty"`
+	MinYear            int      `json:"min_year,omitempty"`
+	MaxYear            int      `json:"max_year,omitempty"`
+	AllowUnknownYear   bool     `json:"allow_unknown_year,omitempty"`
+	Formats            string   `json:"formats,omitempty"`
+	Encodings          string   `json:"encodings,omitempty"`
+	EncodingPreference string   `json:"encoding_preference,omitempty"`
+	Media              string   `json:"media,omitempty"`
+	MinSeeders         int      `json:"min_seeders,omitempty"`
+	MinLeechers        int      `json:"min_leechers,omitempty"`
+	MinFiles           int      `json:"min_files,omitempty"`
+	MaxFiles           int      `json:"max_files,omitempty"`
+	MinScore           int      `json:"min_score,omitempty"`
+	MinSnatched        int      `json:"min_snatched,omitempty"`
+	SceneOnly          bool     `json:"scene_only,omitempty"`
+	SkipScene          bool     `json:"skip_scene,omitempty"`
+	SkipTrumpable      bool     `json:"skip_trumpable,omitempty"`
+	RequireFeatured    bool     `json:"require_featured,omitempty"`
+	SkipFeatured       bool     `json:"skip_featured,omitempty"`
+	CatalogueNumbers   string   `json:"catalogue_numbers,omitempty"`
+	ReleaseTypes       string   `json:"release_types,omitempty"`
+	TagsRequireAny     string   `json:"tags_require_any,omitempty"`
+	TagsExclude        string   `json:"tags_exclude,omitempty"`
+	MaxAge             Duration `json:"max_age,omitempty"`
+	MinRatioBuffer     ByteSize `json:"min_ratio_buffer,omitempty"`
+	RequireArtist      []string `json:"require_artist,omitempty"`
+	RequireArtistRoles []string `json:"require_artist_roles,omitempty"`
+	MinBitDepth        int      `json:"min_bit_depth,omitempty"`
+	AllowedSampleRates string   `json:"allowed_sample_rates,omitempty"`
+	AllowUnknownAudio  bool     `json:"allow_unknown_audio,omitempty"`
+	GroupIDsAllow      []int    `json:"group_ids_allow,omitempty"`
+	GroupIDsBlock      []int    `json:"group_ids_block,omitempty"`
+	PreferBestInGroup  bool     `json:"prefer_best_in_group,omitempty"`
+	QualityPreference  string   `json:"quality_preference,omitempty"`
+	FallbackID         int      `json:"fallback_torrent_id,omitempty"`
+	FallbackHash       string   `json:"fallback_torrent_hash,omitempty"`
+	NoCache            bool     `json:"no_cache,omitempty"`
+	GroupID            int      `json:"group_id,omitempty"`
+	Indexer            string   `json:"indexer"`
+}
+
+// checks if the request names a single torrent, by ID or by infohash.
+func (requestData *RequestData) hasTorrent() bool {
+	return requestData.TorrentID != 0 || requestData.TorrentHash != ""
 }
 
 type ResponseData struct {
+	action string // the API action this response was requested for
+
 	Status   string `json:"status"`
 	Error    string `json:"error"`
 	Response struct {
 		Username string `json:"username"`
 		Stats    struct {
-			Ratio float64 `json:"ratio"`
+			Uploaded      int64   `json:"uploaded"`
+			Downloaded    int64   `json:"downloaded"`
+			Ratio         float64 `json:"ratio"`
+			RequiredRatio float64 `json:"requiredRatio"`
 		} `json:"stats"`
 		Group struct {
-			Name      string `json:"name"`
-			MusicInfo struct {
-				Artists []struct {
-					ID   int    `json:"id"`
-					Name string `json:"name"`
-				} `json:"artists"`
-			} `json:"musicInfo"`
+			ID              int       `json:"id"`
+			Name            string    `json:"name"`
+			RecordLabel     string    `json:"recordLabel"`
+			Year            int       `json:"year"`
+			CatalogueNumber string    `json:"catalogueNumber"`
+			ReleaseType     int       `json:"releaseType"`
+			Tags            []string  `json:"tags"`
+			VanityHouse     bool      `json:"vanityHouse"`
+			MusicInfo       MusicInfo `json:"musicInfo"`
 		} `json:"group"`
 		Torrent *struct {
-			Username        string `json:"username"`
-			Size            int64  `json:"size"`
-			RecordLabel     string `json:"remasterRecordLabel"`
-			ReleaseName     string `json:"filePath"`
-			CatalogueNumber string `json:"remasterCatalogueNumber"`
+			ID              int         `json:"id"`
+			InfoHash        string      `json:"infoHash"`
+			Username        string      `json:"username"`
+			Size            int64       `json:"size"`
+			RecordLabel     string      `json:"remasterRecordLabel"` // the remaster record label, empty for original releases
+			ReleaseName     string      `json:"filePath"`
+			CatalogueNumber string      `json:"remasterCatalogueNumber"`
+			Media           string      `json:"media"`
+			Format          string      `json:"format"`
+			Encoding        string      `json:"encoding"`
+			HasLog          bool        `json:"hasLog"`
+			HasCue          bool        `json:"hasCue"`
+			LogScore        int         `json:"logScore"`
+			Seeders         int         `json:"seeders"`
+			Leechers        int         `json:"leechers"`
+			Snatched        int         `json:"snatched"`
+			Scene           bool        `json:"scene"`
+			Reported        *bool       `json:"reported"`  // nil when the tracker does not expose it
+			Trumpable       *bool       `json:"trumpable"` // nil when the tracker does not expose it
+			Time            trackerTime `json:"time"`
+			RemasterTitle   string      `json:"remasterTitle"`
+			RemasterYear    int         `json:"remasterYear"`
+			FileCount       int         `json:"fileCount"`
+			FileList        string      `json:"fileList"`
+			FreeTorrent     freeTorrent `json:"freeTorrent"`
 		} `json:"torrent"`
+		Torrents []struct {
+			ID       int    `json:"id"`
+			Username string `json:"username"`
+			Size     int64  `json:"size"`
+			Media    string `json:"media"`
+			Format   string `json:"format"`
+			Encoding string `json:"encoding"`
+		} `json:"torrents"` // only set for the torrentgroup action
+		Collage *Collage `json:"collage,omitempty"`
+		Artist  *Artist  `json:"artist,omitempty"`
 	} `json:"response"`
 }
+
+// MusicInfo lists the artists credited on a torrent group, by role.
+type MusicInfo struct {
+	Artists   []ArtistCredit `json:"artists"`
+	With      []ArtistCredit `json:"with"`
+	Composers []ArtistCredit `json:"composers"`
+	Conductor []ArtistCredit `json:"conductor"`
+	DJ        []ArtistCredit `json:"dj"`
+	RemixedBy []ArtistCredit `json:"remixedBy"`
+	Producer  []ArtistCredit `json:"producer"`
+}
+
+type ArtistCredit struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// returns the artists credited in the given role, see config.ArtistRoles.
+func (m MusicInfo) credits(role string) []ArtistCredit {
+	switch role {
+	case "main":
+		return m.Artists
+	case "guest":
+		return m.With
+	case "composer":
+		return m.Composers
+	case "conductor":
+		return m.Conductor
+	case "dj":
+		return m.DJ
+	case "remixer":
+		return m.RemixedBy
+	case "producer":
+		return m.Producer
+	}
+	return nil
+}
+
+type Collage struct {
+	ID                 flexibleInt   `json:"id"`
+	Name               string        `json:"name"`
+	TorrentGroupIDList []flexibleInt `json:"torrentGroupIDList"`
+	TorrentGroups      []struct {
+		ID       flexibleInt `json:"id"`
+		Torrents []struct {
+			TorrentID int `json:"torrentid"`
+		} `json:"torrents"`
+	} `json:"torrentgroups"`
+}
+
+type Artist struct {
+	ID           flexibleInt `json:"id"`
+	Name         string      `json:"name"`
+	TorrentGroup []struct {
+		GroupID     int    `json:"groupId"`
+		GroupName   string `json:"groupName"`
+		GroupYear   int    `json:"groupYear"`
+		ReleaseType int    `json:"releaseType"`
+	} `json:"torrentgroup"`
+}
+
+// UnmarshalJSON decodes the common response fields, and additionally decodes the
+// response object into the action-specific struct for actions whose payload is not nested.
+func (r *ResponseData) UnmarshalJSON(data []byte) error {
+	type plainResponseData ResponseData
+	if err := json.Unmarshal(data, (*plainResponseData)(r)); err != nil {
+		return err
+	}
+
+	var raw struct {
+		Response json.RawMessage `json:"response"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil || len(raw.Response) == 0 {
+		return err
+	}
+
+	switch r.action {
+	case "collage":
+		r.Response.Collage = &Collage{}
+		return json.Unmarshal(raw.Response, r.Response.Collage)
+	case "artist":
+		r.Response.Artist = &Artist{}
+		return json.Unmarshal(raw.Response, r.Response.Artist)
+	}
+	return nil
+}
+
+// ByteSize is a size in bytes that unmarshals from strings like "500MB" or "1.5GiB".
+type ByteSize bytesize.ByteSize
+
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	size, err := config.ParseSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = ByteSize(size)
+	return nil
+}
+
+func (b ByteSize) String() string {
+	return bytesize.ByteSize(b).String()
+}
+
+// freeTorrent is the leech status of a torrent. depending on the tracker it is sent
+// as a boolean (freeleech or not) or as "0"/"1"/"2" (normal, freeleech, neutral leech).
+type freeTorrent int
+
+const (
+	normalLeech freeTorrent = iota
+	freeLeech
+	neutralLeech
+)
+
+func (f *freeTorrent) UnmarshalJSON(data []byte) error {
+	if isFree, err := strconv.ParseBool(string(data)); err == nil {
+		*f = normalLeech
+		if isFree {
+			*f = freeLeech
+		}
+		return nil
+	}
+
+	var value flexibleInt
+	if err := value.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*f = freeTorrent(value)
+	return nil
+}
+
+func (f freeTorrent) FreeLeech() bool {
+	return f == freeLeech
+}
+
+func (f freeTorrent) NeutralLeech() bool {
+	return f == neutralLeech
+}
+
+// flexibleInt accepts IDs the API sends either as JSON numbers or as quoted strings.
+type flexibleInt int
+
+func (i *flexibleInt) UnmarshalJSON(data []byte) error {
+	unquoted, err := strconv.Unquote(string(data))
+	if err != nil {
+		unquoted = string(data)
+	}
+	if unquoted == "" || unquoted == "null" {
+		*i = 0
+		return nil
+	}
+	value, err := strconv.Atoi(unquoted)
+	if err != nil {
+		return err
+	}
+	*i = flexibleInt(value)
+	return nil
+}
+
+// trackerTime is a timestamp from the tracker API. gazelle sends "2006-01-02 15:04:05" without a zone, which is
+// taken as UTC; RFC 3339 strings and unix timestamps are accepted as well. values that cannot be parsed, like
+// "0000-00-00 00:00:00", leave the time zero instead of failing the whole response.
+type trackerTime struct {
+	time.Time
+}
+
+var trackerTimeLayouts = []string{"2006-01-02 15:04:05", time.RFC3339, "2006-01-02T15:04:05"}
+
+func (t *trackerTime) UnmarshalJSON(data []byte) error {
+	t.Time = time.Time{}
+
+	if seconds, err := strconv.ParseInt(string(data), 10, 64); err == nil {
+		if seconds > 0 {
+			t.Time = time.Unix(seconds, 0).UTC()
+		}
+		return nil
+	}
+
+	value, err := strconv.Unquote(string(data))
+	if err != nil {
+		return nil // null or another type, treat as unknown
+	}
+	for _, layout := range trackerTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, strings.TrimSpace(value), time.UTC); err == nil && parsed.Year() > 1 {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return nil
+}
+
+// Duration is a time.Duration that unmarshals from strings like "90m" or "1h".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
@@ -1,21 +1,234 @@
 package api
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding"
 	"encoding/json"
 	"fmt"
+	"html"
+	"mime"
 	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/s0up4200/redactedhook/internal/config"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
 )
 
+// normalizes a string to NFC and folds its case, so "BEYONCÉ" with a combining accent matches "Beyoncé".
+// a Caser keeps state and is not safe to share between requests, so one is made per call.
+func foldString(s string) string {
+	return cases.Fold().String(norm.NFC.String(s))
+}
+
 // takes a slice of strings and returns a new slice with all the labels
-// converted to lowercase and trimmed of any leading or trailing whitespace.
+// HTML-unescaped, case folded and trimmed of any leading or trailing whitespace.
 func normalizeLabels(labels []string) []string {
 	normalized := make([]string, len(labels))
 	for i, label := range labels {
-		normalized[i] = strings.ToLower(strings.TrimSpace(label))
+		normalized[i] = foldString(strings.TrimSpace(html.UnescapeString(label)))
 	}
 	return normalized
 }
+
+// normalizes a tag to the dotted lowercase format the trackers use, e.g. "Hip Hop" becomes "hip.hop".
+func normalizeTag(tag string) string {
+	tag = strings.NewReplacer("_", " ", ".", " ").Replace(foldString(tag))
+	return strings.Join(strings.Fields(tag), ".")
+}
+
+// response fields that carry credentials, e.g. the authkey and passkey of the index action.
+var sensitiveResponseFields = []string{"authkey", "passkey", "api_key", "apikey", "token"}
+
+// pretty prints a tracker response for the logs, replacing credentials and any occurrence of the API key.
+func redactResponse(body []byte, apiKey string) string {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "<unparseable response>"
+	}
+	redactValue(value)
+
+	pretty, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "<unparseable response>"
+	}
+	if apiKey != "" {
+		pretty = bytes.ReplaceAll(pretty, []byte(apiKey), []byte("[REDACTED]"))
+	}
+	return string(pretty)
+}
+
+func redactValue(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if contains(sensitiveResponseFields, strings.ToLower(key)) {
+				v[key] = "[REDACTED]"
+				continue
+			}
+			redactValue(field)
+		}
+	case []interface{}:
+		for _, item := range v {
+			redactValue(item)
+		}
+	}
+}
+
+// both trackers only flag vanity house releases as featured, on the torrent group, and custom profiles are
+// gazelle based as well, so this is used unless featured_flag is set for the indexer.
+const defaultFeaturedFlag = "vanity_house"
+
+// returns the flag featured releases are marked with on the indexer.
+func featuredFlag(indexer string) string {
+	if flag := config.GetConfig().Indexers[indexer].FeaturedFlag; flag != "" {
+		return flag
+	}
+	return defaultFeaturedFlag
+}
+
+// returns whether the torrent is featured according to the flag, ok is false for "none".
+func isFeatured(flag string, torrentData *ResponseData) (featured, ok bool) {
+	switch flag {
+	case "vanity_house":
+		return torrentData.Response.Group.VanityHouse, true
+	}
+	return false, false
+}
+
+// formats an optional tracker flag for logging, "unknown" when the tracker did not send it.
+func formatFlag(flag *bool) string {
+	if flag == nil {
+		return "unknown"
+	}
+	return strconv.FormatBool(*flag)
+}
+
+// splits a comma separated list into normalized entries, dropping empty ones.
+func parseList(list string) []string {
+	return normalizeLabels(splitList(list))
+}
+
+// case folds a catalogue number and collapses any runs of whitespace into a single space.
+func normalizeCatalogueNumber(number string) string {
+	return foldString(strings.Join(strings.Fields(html.UnescapeString(number)), " "))
+}
+
+// splits a comma separated list into trimmed entries, dropping empty ones.
+func splitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+var (
+	bitDepthRegex       = regexp.MustCompile(`(?i)\b(16|24|32)\s?bit\b`)
+	sampleRateKHzRegex  = regexp.MustCompile(`(?i)\b(44\.1|48|88\.2|96|176\.4|192|352\.8|384)\s?khz\b`)
+	sampleRatePairRegex = regexp.MustCompile(`\b(?:16|24|32)(?:\s?bit)?\s?[-/ ]\s?(44\.1|48|88\.2|96|176\.4|192|352\.8|384)\b`)
+)
+
+// counts the entries of a gazelle file list, "name{{{size}}}" entries separated by "|||".
+func countFiles(fileList string) int {
+	count := 0
+	for _, entry := range strings.Split(fileList, "|||") {
+		if strings.TrimSpace(entry) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// splits a comma-separated quality preference into case folded entries, best first.
+func parseQualityPreference(preference string) []string {
+	var entries []string
+	for _, entry := range strings.Split(preference, ",") {
+		if entry = foldString(strings.TrimSpace(entry)); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// returns the position of the first preference entry matching the format and encoding, e.g. "flac lossless",
+// or of a bare format entry like "flac". len(preference) is returned when no entry matches.
+func qualityRank(preference []string, format, encoding string) int {
+	format = foldString(format)
+	quality := format + " " + foldString(encoding)
+	for i, entry := range preference {
+		if entry == quality || entry == format {
+			return i
+		}
+	}
+	return len(preference)
+}
+
+// returns the position of the encoding in the preference, or len(preference) when it is not listed.
+func encodingRank(preference []string, encoding string) int {
+	encoding = foldString(strings.TrimSpace(encoding))
+	for i, entry := range preference {
+		if entry == encoding {
+			return i
+		}
+	}
+	return len(preference)
+}
+
+// returns the bit depth of a lossless encoding, e.g. 24 for "24bit Lossless". gazelle lists plain
+// "Lossless" for 16 bit audio. lossy encodings have no bit depth, 0 is returned for them.
+func parseBitDepth(encoding string) int {
+	if match := bitDepthRegex.FindStringSubmatch(encoding); match != nil {
+		bitDepth, _ := strconv.Atoi(match[1])
+		return bitDepth
+	}
+	if strings.EqualFold(strings.TrimSpace(encoding), "Lossless") {
+		return 16
+	}
+	return 0
+}
+
+// returns the sample rate in kHz. the trackers have no field for it, so it is taken from the first text
+// mentioning one, e.g. "96kHz" or "24-96" in the release name, and CD rips are always 44.1 kHz.
+// 0 is returned when the sample rate is unknown.
+func parseSampleRate(media string, texts ...string) float64 {
+	for _, text := range texts {
+		match := sampleRateKHzRegex.FindStringSubmatch(text)
+		if match == nil {
+			match = sampleRatePairRegex.FindStringSubmatch(text)
+		}
+		if match != nil {
+			sampleRate, _ := strconv.ParseFloat(match[1], 64)
+			return sampleRate
+		}
+	}
+	if strings.EqualFold(media, "CD") {
+		return 44.1
+	}
+	return 0
+}
+
+// parses a comma separated list of sample rates in kHz, e.g. "44.1,96".
+func parseSampleRates(list string) ([]float64, error) {
+	var sampleRates []float64
+	for _, entry := range splitList(list) {
+		sampleRate, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(entry), "khz"), 64)
+		if err != nil || sampleRate <= 0 {
+			return nil, fmt.Errorf("invalid sample rate: %s", entry)
+		}
+		sampleRates = append(sampleRates, sampleRate)
+	}
+	return sampleRates, nil
+}
+
 func contains(slice []string, val string) bool {
 	for _, item := range slice {
 		if item == val {
@@ -26,28 +239,45 @@ func contains(slice []string, val string) bool {
 }
 
 // returns the appropriate API key based on the indexer specified in the `requestData` parameter.
+// custom indexer profiles take their key from the api_key of their [indexers.<name>] section.
 func getAPIKey(requestData *RequestData) (string, error) {
 	switch requestData.Indexer {
 	case "redacted":
 		return requestData.REDKey, nil
 	case "ops":
 		return requestData.OPSKey, nil
-	default:
-		return "", fmt.Errorf("invalid indexer: %s", requestData.Indexer)
 	}
+	if profile, ok := config.GetConfig().Indexers[requestData.Indexer]; ok {
+		return profile.APIKey, nil
+	}
+	return "", fmt.Errorf("invalid indexer: %s", requestData.Indexer)
 }
 
 // sets the Authorization header in an HTTP request header based on the indexer specified
 func setAuthorizationHeader(reqHeader *http.Header, requestData *RequestData) {
-	var apiKey string
-	if requestData.Indexer == "redacted" {
-		apiKey = requestData.REDKey
-	} else if requestData.Indexer == "ops" {
-		apiKey = requestData.OPSKey
-	}
+	apiKey, _ := getAPIKey(requestData)
 	reqHeader.Set("Authorization", apiKey)
 }
 
+// overrides the configured API keys with the ones sent in the X-API-Key-Redacted and X-API-Key-OPS headers, if any.
+func applyAPIKeyHeaders(header http.Header, requestData *RequestData) {
+	if apiKey := header.Get("X-API-Key-Redacted"); apiKey != "" {
+		requestData.REDKey = apiKey
+	}
+	if apiKey := header.Get("X-API-Key-OPS"); apiKey != "" {
+		requestData.OPSKey = apiKey
+	}
+}
+
+// decodes the payload of an HTTP request into requestData. payloads sent as application/x-www-form-urlencoded
+// are read as form fields, anything else as JSON.
+func decodePayload(r *http.Request, requestData *RequestData) error {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+		return decodeFormPayload(r, requestData)
+	}
+	return decodeJSONPayload(r, requestData)
+}
+
 // decodes a JSON payload from an HTTP request and stores it in a struct.
 func decodeJSONPayload(r *http.Request, requestData *RequestData) error {
 	if err := json.NewDecoder(r.Body).Decode(requestData); err != nil {
@@ -55,3 +285,135 @@ func decodeJSONPayload(r *http.Request, requestData *RequestData) error {
 	}
 	return nil
 }
+
+// decodes a form encoded payload from an HTTP request. the fields are named like the JSON ones, and list
+// fields can be repeated or comma separated. like a rule group, the fields are turned into JSON first so both
+// payloads are decoded the same way.
+func decodeFormPayload(r *http.Request, requestData *RequestData) error {
+	if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("invalid form payload")
+	}
+
+	fields, err := formFields(r.PostForm)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, requestData); err != nil {
+		return fmt.Errorf("invalid form payload")
+	}
+	return nil
+}
+
+// converts form values into JSON values of the type of the RequestData field with the same name.
+// fields RequestData does not have are ignored, as they are in JSON payloads.
+func formFields(form url.Values) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	textUnmarshaler := reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	requestType := reflect.TypeOf(RequestData{})
+	for i := 0; i < requestType.NumField(); i++ {
+		field := requestType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		values, ok := form[name]
+		if name == "" || !ok || len(values) == 0 {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Slice {
+			var list []interface{}
+			for _, value := range values {
+				for _, entry := range splitList(value) {
+					converted, err := formValue(name, field.Type.Elem(), entry)
+					if err != nil {
+						return nil, err
+					}
+					list = append(list, converted)
+				}
+			}
+			fields[name] = list
+			continue
+		}
+
+		value := strings.TrimSpace(values[len(values)-1])
+		if reflect.PointerTo(field.Type).Implements(textUnmarshaler) {
+			fields[name] = value // sizes and durations, decoded from their text form
+			continue
+		}
+		converted, err := formValue(name, field.Type, value)
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = converted
+	}
+	return fields, nil
+}
+
+// parses a single form value as the given field type.
+func formValue(name string, fieldType reflect.Type, value string) (interface{}, error) {
+	var converted interface{}
+	var err error
+	switch fieldType.Kind() {
+	case reflect.Int:
+		converted, err = strconv.Atoi(value)
+	case reflect.Float64:
+		converted, err = strconv.ParseFloat(value, 64)
+	case reflect.Bool:
+		converted, err = strconv.ParseBool(value)
+	default:
+		converted = value
+	}
+	if err != nil {
+		return nil, &ValidationError{Field: name, Message: fmt.Sprintf("invalid %s: %s", name, value)}
+	}
+	return converted, nil
+}
+
+// parses a Retry-After header value given either as delay-seconds or as an HTTP-date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if wait := date.Sub(now); wait > 0 {
+		return wait, true
+	}
+	return 0, true
+}
+
+// returns the incoming request ID when it is safe to log, or a new random UUID otherwise.
+func requestIDFromHeader(header string) string {
+	if header != "" && len(header) <= 128 && strings.IndexFunc(header, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.:", r))
+	}) < 0 {
+		return header
+	}
+	return newRequestID()
+}
+
+// generates a random (version 4) UUID.
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -1,14 +1,23 @@
 package api
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"regexp"
 	"strings"
 
 	"github.com/rs/zerolog/log"
+	"github.com/s0up4200/redactedhook/internal/config"
+	"golang.org/x/text/unicode/norm"
 )
 
+// matches a hex encoded SHA-1 infohash.
+var infohashRegex = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
 // verifyAPIKey checks if the provided API key matches the expected one.
 func verifyAPIKey(headerAPIKey string, expectedAPIKey string) error {
 	if expectedAPIKey == "" || headerAPIKey != expectedAPIKey {
@@ -17,6 +26,47 @@ func verifyAPIKey(headerAPIKey string, expectedAPIKey string) error {
 	return nil
 }
 
+// verifySignature checks the X-Signature header against the HMAC-SHA256 of the request body.
+// the signature is hex encoded, optionally prefixed with "sha256=".
+func verifySignature(body []byte, signature string, secret string) error {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+	provided, err := hex.DecodeString(signature)
+	if err != nil || signature == "" {
+		return fmt.Errorf("invalid or missing signature")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(provided, mac.Sum(nil)) {
+		return fmt.Errorf("invalid or missing signature")
+	}
+	return nil
+}
+
+// verifyBearerToken checks the Authorization header carries the expected bearer token, in constant time.
+func verifyBearerToken(authorization string, expectedToken string) error {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(expectedToken)) != 1 {
+		return fmt.Errorf("invalid or missing bearer token")
+	}
+	return nil
+}
+
+// authorizeRequest checks the X-API-Token header and, when a webhook_token is configured, the bearer token.
+func authorizeRequest(r *http.Request) error {
+	cfg := config.GetConfig()
+	if err := verifyAPIKey(r.Header.Get("X-API-Token"), cfg.Authorization.APIToken); err != nil {
+		return err
+	}
+	if cfg.Authorization.WebhookToken != "" {
+		if err := verifyBearerToken(r.Header.Get("Authorization"), cfg.Authorization.WebhookToken); err != nil {
+			log.Warn().Str("remote_addr", r.RemoteAddr).Msgf("Rejected %s with an invalid bearer token", r.URL.Path)
+			return err
+		}
+	}
+	return nil
+}
+
 // validateRequestMethod ensures the request uses the POST method.
 func validateRequestMethod(method string) error {
 	if method != http.MethodPost {
@@ -29,58 +79,161 @@ func validateRequestMethod(method string) error {
 func validateRequestData(requestData *RequestData) error {
 	safeCharacterRegex := regexp.MustCompile(`^[\p{L}\p{N}\s&,-]+$`)
 
-	if requestData.Indexer != "ops" && requestData.Indexer != "redacted" {
+	if _, err := determineAPIBase(requestData.Indexer); err != nil {
 		errMsg := fmt.Sprintf("invalid indexer: %s", requestData.Indexer)
 		log.Debug().Msg(errMsg)
-		return fmt.Errorf(errMsg)
+		return &ValidationError{Field: "indexer", Message: errMsg}
 	}
 
 	if requestData.TorrentID > 999999999 {
 		errMsg := fmt.Sprintf("invalid torrent ID: %d", requestData.TorrentID)
 		log.Debug().Msg(errMsg)
-		return fmt.Errorf(errMsg)
+		return &ValidationError{Field: "torrent_id", Message: errMsg}
 	}
 
 	if requestData.REDKey != "" && len(requestData.REDKey) > 42 {
 		errMsg := "REDKey is too long"
 		log.Debug().Msg(errMsg)
-		return fmt.Errorf(errMsg)
+		return &ValidationError{Field: "red_apikey", Message: errMsg}
 	}
 
 	if requestData.OPSKey != "" && len(requestData.OPSKey) > 120 {
 		errMsg := "OPSKey is too long"
 		log.Debug().Msg(errMsg)
-		return fmt.Errorf(errMsg)
+		return &ValidationError{Field: "ops_apikey", Message: errMsg}
 	}
 
 	if requestData.MinRatio < 0 || requestData.MinRatio > 999.999 {
 		errMsg := "minRatio must be between 0 and 999.999"
 		log.Debug().Msg(errMsg)
-		return fmt.Errorf(errMsg)
+		return &ValidationError{Field: "minratio", Message: errMsg}
 	}
 
 	if requestData.MaxSize > 0 && requestData.MinSize > requestData.MaxSize {
 		errMsg := "minsize cannot be greater than maxsize"
 		log.Debug().Msg(errMsg)
-		return fmt.Errorf(errMsg)
+		return &ValidationError{Field: "minsize", Message: errMsg}
+	}
+
+	if requestData.MinLogScore < 0 || requestData.MinLogScore > 100 {
+		errMsg := "min_log_score must be between 0 and 100"
+		log.Debug().Msg(errMsg)
+		return &ValidationError{Field: "min_log_score", Message: errMsg}
+	}
+
+	if requestData.FreeleechOnly && requestData.SkipFreeleech {
+		errMsg := "freeleech_only and skip_freeleech cannot both be set"
+		log.Debug().Msg(errMsg)
+		return &ValidationError{Field: "freeleech_only", Message: errMsg}
+	}
+
+	if requestData.MinYear < 0 || requestData.MaxYear < 0 {
+		errMsg := "min_year and max_year cannot be negative"
+		log.Debug().Msg(errMsg)
+		return &ValidationError{Field: "min_year", Message: errMsg}
+	}
+
+	if requestData.MaxYear > 0 && requestData.MinYear > requestData.MaxYear {
+		errMsg := "min_year cannot be greater than max_year"
+		log.Debug().Msg(errMsg)
+		return &ValidationError{Field: "min_year", Message: errMsg}
+	}
+
+	if requestData.MinSeeders < 0 {
+		errMsg := "min_seeders cannot be negative"
+		log.Debug().Msg(errMsg)
+		return &ValidationError{Field: "min_seeders", Message: errMsg}
+	}
+
+	if requestData.MinLeechers < 0 {
+		errMsg := "min_leechers cannot be negative"
+		log.Debug().Msg(errMsg)
+		return &ValidationError{Field: "min_leechers", Message: errMsg}
+	}
+
+	if requestData.MinSnatched < 0 {
+		errMsg := "min_snatched cannot be negative"
+		log.Debug().Msg(errMsg)
+		return &ValidationError{Field: "min_snatched", Message: errMsg}
+	}
+
+	if requestData.RequireFeatured && requestData.SkipFeatured {
+		errMsg := "require_featured and skip_featured cannot both be set"
+		log.Debug().Msg(errMsg)
+		return &ValidationError{Field: "require_featured", Message: errMsg}
+	}
+
+	if requestData.SceneOnly && requestData.SkipScene {
+		errMsg := "scene_only and skip_scene cannot both be set"
+		log.Debug().Msg(errMsg)
+		return &ValidationError{Field: "scene_only", Message: errMsg}
+	}
+
+	for _, releaseType := range splitList(requestData.ReleaseTypes) {
+		if _, ok := config.ReleaseTypeCode(releaseType); !ok {
+			errMsg := fmt.Sprintf("unknown release type: %s", releaseType)
+			log.Debug().Msg(errMsg)
+			return &ValidationError{Field: "release_types", Message: errMsg}
+		}
+	}
+
+	if requestData.MaxAge < 0 {
+		errMsg := "max_age cannot be negative"
+		log.Debug().Msg(errMsg)
+		return &ValidationError{Field: "max_age", Message: errMsg}
+	}
+
+	for _, role := range requestData.RequireArtistRoles {
+		if !contains(config.ArtistRoles, strings.ToLower(strings.TrimSpace(role))) {
+			errMsg := fmt.Sprintf("unknown artist role: %s, expected one of %s", role, strings.Join(config.ArtistRoles, ", "))
+			log.Debug().Msg(errMsg)
+			return &ValidationError{Field: "require_artist_roles", Message: errMsg}
+		}
+	}
+
+	if requestData.MinBitDepth < 0 {
+		errMsg := "min_bit_depth cannot be negative"
+		log.Debug().Msg(errMsg)
+		return &ValidationError{Field: "min_bit_depth", Message: errMsg}
+	}
+
+	if _, err := parseSampleRates(requestData.AllowedSampleRates); err != nil {
+		log.Debug().Msg(err.Error())
+		return &ValidationError{Field: "allowed_sample_rates", Message: err.Error()}
+	}
+
+	if requestData.MinFiles < 0 || requestData.MaxFiles < 0 {
+		errMsg := "min_files and max_files cannot be negative"
+		log.Debug().Msg(errMsg)
+		return &ValidationError{Field: "min_files", Message: errMsg}
+	}
+
+	if requestData.MaxFiles > 0 && requestData.MinFiles > requestData.MaxFiles {
+		errMsg := "min_files cannot be greater than max_files"
+		log.Debug().Msg(errMsg)
+		return &ValidationError{Field: "min_files", Message: errMsg}
 	}
 
 	if requestData.Uploaders != "" {
 		if requestData.Mode != "whitelist" && requestData.Mode != "blacklist" {
 			errMsg := fmt.Sprintf("mode must be either 'whitelist' or 'blacklist', got '%s'", requestData.Mode)
 			log.Debug().Msg(errMsg)
-			return fmt.Errorf(errMsg)
+			return &ValidationError{Field: "mode", Message: errMsg}
 		}
 	}
 
-	if requestData.RecordLabel != "" {
-		labels := strings.Split(requestData.RecordLabel, ",")
+	for field, recordLabels := range map[string]string{"record_labels": requestData.RecordLabel, "blocked_record_labels": requestData.BlockedRecordLabel} {
+		if recordLabels == "" {
+			continue
+		}
+		labels := strings.Split(recordLabels, ",")
 		for _, label := range labels {
-			trimmedLabel := strings.TrimSpace(label)
+			// composed first, so accents sent as combining marks are accepted like precomposed ones
+			trimmedLabel := norm.NFC.String(strings.TrimSpace(label))
 			if !safeCharacterRegex.MatchString(trimmedLabel) {
 				errMsg := "recordLabels field should only contain alphanumeric characters, spaces, and safe special characters"
 				log.Debug().Msg(errMsg)
-				return fmt.Errorf(errMsg)
+				return &ValidationError{Field: field, Message: errMsg}
 			}
 		}
 	}
@@ -88,13 +241,46 @@ func validateRequestData(requestData *RequestData) error {
 	return nil
 }
 
-// checks if a given indexer string is valid or not.
-func validateIndexer(indexer string) error {
-	if indexer != "ops" && indexer != "redacted" {
-		if indexer == "" {
-			return fmt.Errorf("no indexer provided")
+// Validate checks that the payload names a supported indexer and a torrent ID (or a batch of them) before any API work is done,
+// and that all filter values are valid. failures are returned as a *ValidationError naming the offending field.
+func (requestData *RequestData) Validate() error {
+	if requestData.Indexer == "" {
+		return &ValidationError{Field: "indexer", Message: "no indexer provided"}
+	}
+
+	if _, err := determineAPIBase(requestData.Indexer); err != nil {
+		return &ValidationError{Field: "indexer", Message: fmt.Sprintf("invalid indexer: %s", requestData.Indexer)}
+	}
+
+	if len(requestData.TorrentIDs) > maxBatchSize {
+		return &ValidationError{Field: "torrent_ids", Message: fmt.Sprintf("torrent_ids can contain at most %d IDs", maxBatchSize)}
+	}
+
+	for _, torrentID := range requestData.TorrentIDs {
+		if torrentID <= 0 || torrentID > 999999999 {
+			return &ValidationError{Field: "torrent_ids", Message: fmt.Sprintf("invalid torrent ID: %d", torrentID)}
 		}
-		return fmt.Errorf("invalid indexer: %s", indexer)
 	}
-	return nil
+
+	if requestData.TorrentHash != "" && !infohashRegex.MatchString(requestData.TorrentHash) {
+		return &ValidationError{Field: "torrent_hash", Message: fmt.Sprintf("invalid torrent hash: %s", requestData.TorrentHash)}
+	}
+
+	if requestData.FallbackHash != "" && !infohashRegex.MatchString(requestData.FallbackHash) {
+		return &ValidationError{Field: "fallback_torrent_hash", Message: fmt.Sprintf("invalid torrent hash: %s", requestData.FallbackHash)}
+	}
+
+	if requestData.TorrentID < 0 {
+		return &ValidationError{Field: "torrent_id", Message: fmt.Sprintf("invalid torrent ID: %d", requestData.TorrentID)}
+	}
+
+	if requestData.FallbackID < 0 || requestData.FallbackID > 999999999 {
+		return &ValidationError{Field: "fallback_torrent_id", Message: fmt.Sprintf("invalid torrent ID: %d", requestData.FallbackID)}
+	}
+
+	if requestData.TorrentID <= 0 && len(requestData.TorrentIDs) == 0 && requestData.TorrentHash == "" {
+		return &ValidationError{Field: "torrent_id", Message: "torrent_id must be a positive integer"}
+	}
+
+	return validateRequestData(requestData)
 }
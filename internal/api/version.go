@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+
+	"github.com/rs/zerolog/log"
+)
+
+// BuildInfo describes the running build, as set through ldflags at build time.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+var buildInfo = BuildInfo{Version: "dev", GoVersion: runtime.Version()}
+
+// SetBuildInfo sets the build reported by /version and in the default User-Agent of API requests.
+// an empty version keeps "dev", for builds made without ldflags.
+func SetBuildInfo(version, commit, buildDate string) {
+	if version != "" {
+		buildInfo.Version = version
+	}
+	buildInfo.Commit = commit
+	buildInfo.BuildDate = buildDate
+}
+
+// serves the version, git commit and build date of the running build, so bug reports can name it exactly.
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(buildInfo); err != nil {
+		log.Error().Err(err).Msg("Failed to encode version response")
+	}
+}
@@ -8,6 +8,7 @@ import (
 )
 
 const (
+	envPrefix             = "REDACTEDHOOK_" // joined with "_", so variables start with REDACTEDHOOK__
 	defaultConfigFileName = "config.toml"
 	defaultConfigType     = "toml"
 	defaultConfigDir      = ".config/redactedhook"
@@ -23,17 +24,23 @@ func CreateConfigFile() string {
 api_token = "" # generate with "redactedhook generate-apitoken"
 # the api_token needs to be set as a header for the webhook to work
 # eg. X-API-Token=asd987gsd98g7324kjh142kjh
+#hmac_secret = "" # when set, the request body must be signed with HMAC-SHA256 and sent as the X-Signature header
+#webhook_token = "" # when set, requests must send it as an "Authorization: Bearer <token>" header
+#allowed_ips = [] # IPs and CIDRs allowed to call the webhook and cache endpoints, e.g., ["127.0.0.1", "192.168.1.0/24"], empty allows any
+#trusted_proxies = [] # reverse proxies whose X-Forwarded-For header is trusted to name the client, e.g., ["172.17.0.1"]
 
 [indexer_keys]
-#red_apikey = "" # generate in user settings, needs torrent and user privileges
-#ops_apikey = "" # generate in user settings, needs torrent and user privileges
+#red_apikey = ""                # generate in user settings, needs torrent and user privileges
+#ops_apikey = ""                # generate in user settings, needs torrent and user privileges
+#verify_keys_on_startup = false # make one index call per configured key on boot and exit if a key is rejected
 
 [userid]
 #red_user_id = 0 # from /user.php?id=xxx
 #ops_user_id = 0 # from /user.php?id=xxx
 
 [ratio]
-#minratio = 0.6 # reject releases if you are below this ratio
+#minratio = 0.6        # reject releases if you are below this ratio
+#min_ratio_buffer = "" # reject releases that would leave less than this buffer before hitting your required ratio, e.g., "10GB"
 
 [sizecheck]
 #minsize = "100MB" # minimum size for checking, e.g., "10MB"
@@ -42,13 +49,182 @@ api_token = "" # generate with "redactedhook generate-apitoken"
 [uploaders]
 #uploaders = "greatest-uploader" # comma separated list of uploaders to allow
 #mode = "whitelist" # whitelist or blacklist
+#allow = "" # comma separated list of uploaders to allow, checked case-insensitively
+#block = "" # comma separated list of uploaders to always reject, wins over allow
 
 [record_labels]
 #record_labels = "" # comma separated list of record labels to filter for
+#blocked_record_labels = "" # comma separated list of record labels to always reject
+
+[log_score]
+#min_log_score = 100 # reject CD rips with a log score below this, 0 disables the check
+#strict = false      # also reject releases without a log (WEB, Vinyl, etc.)
+#require_log = false # reject CD rips without a log
+#require_cue = false # reject CD rips without a cue file
+
+[freeleech]
+#freeleech_only = false # only allow freeleech and neutral leech torrents
+#skip_freeleech = false # reject freeleech and neutral leech torrents, can't be combined with freeleech_only
+
+[year]
+#min_year = 1990        # reject releases from before this year, 0 means no lower bound
+#max_year = 1999        # reject releases from after this year, 0 means no upper bound
+#allow_unknown = false  # allow releases without a year when a range is set
+
+[format]
+#formats = "FLAC"                        # comma separated list of formats to allow, e.g., "FLAC,MP3"
+#encodings = "Lossless,24bit Lossless"   # comma separated list of encodings to allow, e.g., "320,V0 (VBR)"
+#encoding_preference = ""                # encodings from most to least preferred, e.g., "Lossless,24bit Lossless", the best one in the group is taken
+
+[media]
+#media = "WEB" # comma separated list of media to allow, e.g., "CD,WEB,Vinyl,SACD"
+
+[seeders]
+#min_seeders = 1 # reject torrents with fewer seeders than this, 0 disables the check
+
+[leechers]
+#min_leechers = 5 # reject torrents with fewer leechers than this, 0 disables the check
+
+[snatched]
+#min_snatched = 5 # reject torrents snatched fewer times than this, 0 disables the check
+
+[release_name]
+#must_match = []     # regexes the release name must all match, e.g., ['(?i)deluxe']
+#must_not_match = [] # regexes the release name must not match, e.g., ['(?i)\b(web|cd)rip\b']
+
+[remaster]
+#title_must_match = []     # regexes the remaster title must all match, e.g., ['(?i)deluxe']
+#title_must_not_match = [] # regexes the remaster title must not match, e.g., ['(?i)\bclean\b']
+#years = []                # edition years to allow, e.g., [1990, 2009], empty allows any
+
+[scene]
+#scene_only = false # only allow scene releases
+#skip_scene = false # reject scene releases, can't be combined with scene_only
+
+[trumpable]
+#skip_trumpable = false # reject torrents flagged as reported or trumpable on the tracker
+
+[featured]
+#require_featured = false # only allow releases the indexer flags as featured, see featured_flag under the indexers
+#skip_featured = false    # reject releases the indexer flags as featured, can't be combined with require_featured
+
+[catalogue]
+#catalogue_numbers = "" # comma separated list of catalogue numbers to allow, e.g., "SRCS 1234,WPCL-12345"
+
+[release_types]
+#release_types = ["Album", "EP"] # release types to allow, see the README for the valid names
+
+[tags]
+#require_any = ["electronic", "hip hop"] # at least one of these tags must be present, matched in the tracker's dotted format (hip.hop)
+#exclude = ["christmas"]                 # reject torrent groups carrying any of these tags
+
+[age]
+#max_age = "" # reject torrents uploaded longer ago than this, e.g., "1h" or "30m", empty disables the check
+
+[artists]
+#require_artist = ["Aphex Twin"] # at least one of these artists must be credited on the torrent group, matched case-insensitively
+#roles = []                      # roles they must be credited in: main, guest, composer, conductor, dj, remixer or producer, empty allows any
+
+[audio]
+#min_bit_depth = 0           # lowest bit depth to allow, e.g., 24 for hi-res only, 0 disables the check
+#allowed_sample_rates = []   # sample rates in kHz to allow, e.g., [96, 192], empty allows any
+#allow_unknown = false       # allow torrents whose bit depth or sample rate is unknown, e.g., lossy encodes
+
+[group_ids]
+#allow = [] # only allow torrent groups with one of these IDs, empty allows any
+#block = [] # always reject torrent groups with these IDs, e.g., known mislabeled or fake releases
+
+[best_in_group]
+#enabled = false # reject a torrent when its group has a torrent ranked higher in preference
+# formats and encodings from best to worst, a bare format like "FLAC" matches any encoding
+#preference = ["FLAC 24bit Lossless", "FLAC Lossless", "MP3 320", "MP3 V0 (VBR)"]
+
+[files]
+#min_files = 0  # reject torrents with fewer files than this, 0 means no lower bound
+#max_files = 40 # reject torrents with more files than this, e.g., to skip releases bloated with scans, 0 means no upper bound
+
+[scoring]
+#min_score = 0 # reject torrents scoring below this, only used once there are [[scoring.rules]], see the README
+
+[indexers.redacted]
+#timeout = 10                  # seconds to wait for an API response
+#rate_limit = 10               # API requests per 10 seconds, redacted allows 10
+#burst = 10                    # requests that may be sent back to back
+#smooth_burst = false          # space requests evenly instead of bursting after idle periods, see the README
+#adaptive_latency = 0          # target p95 API response time in milliseconds, slower responses lower the rate limit, 0 disables
+#adaptive_min_rate_limit = 1   # lowest requests per 10 seconds the adaptive rate limit goes down to
+#auth_header = "Authorization" # header the API key is sent in
+#auth_format = "{key}"         # value of the auth header, e.g., "token {key}", {key} is replaced by the API key
+#api_base = ""                 # override the API endpoint, e.g., for a staging mirror, defaults to https://redacted.ch/ajax.php
+#breaker_threshold = 5         # consecutive failed requests before the indexer is short-circuited, 0 disables the circuit breaker
+#breaker_cooldown = 30         # seconds to short-circuit requests before a single probe is let through
+#fallback_indexer = ""         # check cross-listed torrents against this indexer while redacted is unavailable, e.g., "ops"
+#featured_flag = ""            # flag marking featured releases for require_featured and skip_featured, defaults to "vanity_house", "none" if there is none
+
+[indexers.ops]
+#timeout = 10                  # seconds to wait for an API response
+#rate_limit = 5                # API requests per 10 seconds, orpheus allows 5
+#burst = 5                     # requests that may be sent back to back
+#smooth_burst = false          # space requests evenly instead of bursting after idle periods, see the README
+#adaptive_latency = 0          # target p95 API response time in milliseconds, slower responses lower the rate limit, 0 disables
+#adaptive_min_rate_limit = 1   # lowest requests per 10 seconds the adaptive rate limit goes down to
+#auth_header = "Authorization" # header the API key is sent in
+#auth_format = "{key}"         # value of the auth header, e.g., "token {key}", {key} is replaced by the API key
+#api_base = ""                 # override the API endpoint, e.g., for a staging mirror, defaults to https://orpheus.network/ajax.php
+#breaker_threshold = 5         # consecutive failed requests before the indexer is short-circuited, 0 disables the circuit breaker
+#breaker_cooldown = 30         # seconds to short-circuit requests before a single probe is let through
+#fallback_indexer = ""         # check cross-listed torrents against this indexer while ops is unavailable, e.g., "redacted"
+#featured_flag = ""            # flag marking featured releases for require_featured and skip_featured, defaults to "vanity_house", "none" if there is none
+
+# custom profiles for other gazelle based trackers, selected with "indexer": "<name>" in the payload
+#[indexers.mytracker]
+#api_base = "https://tracker.example/ajax.php" # required for custom profiles
+#api_key = ""                                  # generate in user settings, needs torrent and user privileges
+#user_id = 0                                   # from /user.php?id=xxx, used for the ratio check
+#rate_limit = 5                                # API requests per 10 seconds, timeout, burst and breaker settings work as above
+
+[http_client]
+#max_attempts = 3             # attempts per API request; connection errors, timeouts, 5xx and 429 are retried
+#retry_delay = 200            # base backoff in milliseconds, doubled on each retry
+#max_body_size = 5242880      # largest API response accepted in bytes, larger responses are rejected
+#max_concurrent_requests = 0  # API requests in flight at once across all indexers, others wait for a free slot, 0 is unlimited (requires restart)
+#max_idle_conns = 100         # idle connections kept open across all indexers (requires restart)
+#max_idle_conns_per_host = 10 # idle connections kept open per indexer (requires restart)
+#idle_conn_timeout = 90       # seconds before an idle connection is closed (requires restart)
+#http2 = true                 # use HTTP/2 with trackers that support it, false forces HTTP/1.1 for proxies that mishandle h2 (requires restart)
+#user_agent = ""              # User-Agent sent to trackers, empty uses redactedhook/<version>
+
+[cache]
+#ttl = 300              # seconds to keep API responses cached, 0 disables the cache
+#action_ttl = {}        # override ttl per action: torrent, torrentgroup, user, collage or artist, e.g., { torrent = 120, artist = 21600 }
+#ttl_jitter = 10        # vary the TTL randomly by up to this percentage, so entries cached together do not all expire together
+#negative_ttl = 60      # seconds to keep tracker failures like a bad ID cached, so retries do not hit the API, 0 disables it
+#max_entries = 1000     # responses kept before the least recently used is evicted
+#persist_path = ""      # save the cache to this file and warm it on startup, e.g., "cache.json"
+#resolve_hashes = false # cache torrents looked up by infohash under their ID, so lookups by hash and by ID share one entry
+
+[server]
+#address = "127.0.0.1"     # address to listen on, SERVER_ADDRESS overrides it (requires restart)
+#port = "42135"            # port to listen on, SERVER_PORT overrides it (requires restart)
+#listen_addr = ""          # host:port or unix:/path/to/socket, replaces address and port and their env vars when set (requires restart)
+#shutdown_timeout = 30     # seconds to wait for in-flight checks to finish on SIGINT/SIGTERM
+#dry_run = false           # evaluate filters and log the would-be decision, but always respond with 200
+#reject_status = 0         # answer every rejection with this status code, e.g., 420, 0 keeps the status of the failed check
+#include_evaluated = false # add the checks that passed and the torrent attributes they saw to approvals, for audit logs
+#client_rate_limit = 0     # webhook requests per minute allowed from one client address, e.g., 120, 0 disables the limit
+#global_rate_limit = 0     # webhook requests per minute allowed from all clients together, 0 disables the limit
+#debug_history = 0         # keep the last N webhook requests and their verdicts in memory for /debug/requests, 0 disables it
+#on_api_error = "reject"   # "reject" or "approve" torrents when the tracker cannot be reached, approve skips every check
+#tls_cert = ""             # serve HTTPS with this certificate, reloaded when the file changes, e.g., "/etc/ssl/redactedhook.crt"
+#tls_key = ""              # private key for tls_cert, both must be set to enable HTTPS (requires restart)
 
 [logs]
 loglevel = "trace"               # trace, debug, info
+log_format = "console"           # console or json, e.g., for shipping logs to Loki (requires restart)
+log_api_responses = false        # log every tracker response at trace level, with API keys and tokens redacted
+debug_lines_per_second = 0       # sample trace and debug logs to at most this many lines per second, warnings and errors are always logged, 0 logs everything
 logtofile = false                # Set to true to enable logging to a file
+#logtoconsole = true             # Set to false to only log to the file while logtofile is enabled
 logfilepath = "redactedhook.log" # Path to the log file
 maxsize = 10                     # Max file size in MB
 maxbackups = 3                   # Max number of old log files to keep
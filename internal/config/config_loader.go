@@ -2,7 +2,15 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/inhies/go-bytesize"
@@ -19,18 +27,128 @@ func InitConfig(configPath string) {
 
 func setupViper(configFile string) {
 	// Set default values before reading the config file
+	viper.SetDefault("authorization.hmac_secret", "")
+	viper.SetDefault("authorization.webhook_token", "")
+	viper.SetDefault("authorization.allowed_ips", []string{})
+	viper.SetDefault("authorization.trusted_proxies", []string{})
+	viper.SetDefault("indexer_keys.verify_keys_on_startup", false)
 	viper.SetDefault("userid.red_user_id", 0)
 	viper.SetDefault("userid.ops_user_id", 0)
 	viper.SetDefault("ratio.minratio", 0)
+	viper.SetDefault("ratio.min_ratio_buffer", "")
 	viper.SetDefault("sizecheck.minsize", "")
 	viper.SetDefault("sizecheck.maxsize", "")
 	viper.SetDefault("uploaders.uploaders", "")
 	viper.SetDefault("uploaders.mode", "")
+	viper.SetDefault("uploaders.allow", "")
+	viper.SetDefault("uploaders.block", "")
 	viper.SetDefault("record_labels.record_labels", "")
+	viper.SetDefault("record_labels.blocked_record_labels", "")
+	viper.SetDefault("log_score.min_log_score", 0)
+	viper.SetDefault("log_score.strict", false)
+	viper.SetDefault("log_score.require_log", false)
+	viper.SetDefault("log_score.require_cue", false)
+	viper.SetDefault("freeleech.freeleech_only", false)
+	viper.SetDefault("freeleech.skip_freeleech", false)
+	viper.SetDefault("year.min_year", 0)
+	viper.SetDefault("year.max_year", 0)
+	viper.SetDefault("year.allow_unknown", false)
+	viper.SetDefault("format.formats", "")
+	viper.SetDefault("format.encodings", "")
+	viper.SetDefault("format.encoding_preference", "")
+	viper.SetDefault("media.media", "")
+	viper.SetDefault("seeders.min_seeders", 0)
+	viper.SetDefault("leechers.min_leechers", 0)
+	viper.SetDefault("snatched.min_snatched", 0)
+	viper.SetDefault("release_name.must_match", []string{})
+	viper.SetDefault("release_name.must_not_match", []string{})
+	viper.SetDefault("remaster.title_must_match", []string{})
+	viper.SetDefault("remaster.title_must_not_match", []string{})
+	viper.SetDefault("remaster.years", []int{})
+	viper.SetDefault("scene.scene_only", false)
+	viper.SetDefault("scene.skip_scene", false)
+	viper.SetDefault("trumpable.skip_trumpable", false)
+	viper.SetDefault("featured.require_featured", false)
+	viper.SetDefault("featured.skip_featured", false)
+	viper.SetDefault("catalogue.catalogue_numbers", "")
+	viper.SetDefault("release_types.release_types", []string{})
+	viper.SetDefault("tags.require_any", []string{})
+	viper.SetDefault("tags.exclude", []string{})
+	viper.SetDefault("age.max_age", "")
+	viper.SetDefault("artists.require_artist", []string{})
+	viper.SetDefault("artists.roles", []string{})
+	viper.SetDefault("audio.min_bit_depth", 0)
+	viper.SetDefault("audio.allowed_sample_rates", []float64{})
+	viper.SetDefault("audio.allow_unknown", false)
+	viper.SetDefault("group_ids.allow", []int{})
+	viper.SetDefault("group_ids.block", []int{})
+	viper.SetDefault("best_in_group.preference", []string{"FLAC 24bit Lossless", "FLAC Lossless", "MP3 320", "MP3 V0 (VBR)"})
+	viper.SetDefault("files.min_files", 0)
+	viper.SetDefault("files.max_files", 0)
+	viper.SetDefault("scoring.min_score", 0)
+	viper.SetDefault("indexers.redacted.timeout", 10)
+	viper.SetDefault("indexers.redacted.rate_limit", 10)
+	viper.SetDefault("indexers.redacted.burst", 10)
+	viper.SetDefault("indexers.ops.timeout", 10)
+	viper.SetDefault("indexers.ops.rate_limit", 5)
+	viper.SetDefault("indexers.ops.burst", 5)
+	for _, name := range []string{"redacted", "ops"} {
+		viper.SetDefault("indexers."+name+".breaker_threshold", 5)
+		viper.SetDefault("indexers."+name+".breaker_cooldown", 30)
+		viper.SetDefault("indexers."+name+".api_base", "")
+		viper.SetDefault("indexers."+name+".smooth_burst", false)
+		viper.SetDefault("indexers."+name+".auth_header", "Authorization")
+		viper.SetDefault("indexers."+name+".auth_format", "{key}")
+		viper.SetDefault("indexers."+name+".adaptive_latency", 0)
+		viper.SetDefault("indexers."+name+".adaptive_min_rate_limit", 1)
+	}
+	viper.SetDefault("http_client.max_attempts", 3)
+	viper.SetDefault("http_client.http2", true)
+	viper.SetDefault("http_client.user_agent", "")
+	viper.SetDefault("http_client.retry_delay", 200)
+	viper.SetDefault("http_client.max_body_size", 5242880)
+	viper.SetDefault("http_client.max_concurrent_requests", 0)
+	viper.SetDefault("http_client.max_idle_conns", 100)
+	viper.SetDefault("http_client.max_idle_conns_per_host", 10)
+	viper.SetDefault("http_client.idle_conn_timeout", 90)
+	viper.SetDefault("cache.ttl", 300)
+	viper.SetDefault("cache.negative_ttl", 60)
+	viper.SetDefault("cache.ttl_jitter", 10)
+	viper.SetDefault("cache.max_entries", 1000)
+	viper.SetDefault("cache.persist_path", "")
+	viper.SetDefault("cache.resolve_hashes", false)
+	viper.SetDefault("server.address", "127.0.0.1")
+	viper.SetDefault("server.port", "42135")
+	viper.SetDefault("server.listen_addr", "")
+	viper.SetDefault("server.shutdown_timeout", 30)
+	viper.SetDefault("server.dry_run", false)
+	viper.SetDefault("server.on_api_error", "reject")
+	viper.SetDefault("server.reject_status", 0)
+	viper.SetDefault("server.include_evaluated", false)
+	viper.SetDefault("server.client_rate_limit", 0)
+	viper.SetDefault("server.global_rate_limit", 0)
+	viper.SetDefault("server.debug_history", 0)
+	viper.SetDefault("server.tls_cert", "")
+	viper.SetDefault("server.tls_key", "")
+	viper.SetDefault("logs.loglevel", "info")
+	viper.SetDefault("logs.log_format", "console")
+	viper.SetDefault("logs.log_api_responses", false)
+	viper.SetDefault("logs.debug_lines_per_second", 0)
+	viper.SetDefault("logs.logtofile", false)
+	viper.SetDefault("logs.logtoconsole", true)
+	viper.SetDefault("logs.logfilepath", "redactedhook.log")
+	viper.SetDefault("logs.maxsize", 10)
+	viper.SetDefault("logs.maxbackups", 3)
+	viper.SetDefault("logs.maxage", 28)
+	viper.SetDefault("logs.compress", false)
 
+	// every key can be overridden with an environment variable, e.g., REDACTEDHOOK__INDEXER_KEYS__RED_APIKEY
+	// for indexer_keys.red_apikey. environment variables take precedence over the config file.
 	viper.SetConfigType(defaultConfigType)
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "__"))
 	viper.AutomaticEnv()
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	bindEnv()
 	viper.SetConfigFile(configFile)
 
 	// Uncomment this if you want to ensure the config file exists
@@ -39,9 +157,146 @@ func setupViper(configFile string) {
 	//	log.Fatal().Err(err).Msg("Failed to create or verify config file")
 	// }
 
+	if _, err := os.Stat(configFile); errors.Is(err, os.ErrNotExist) {
+		log.Warn().Msgf("Config file %s not found, using environment variables and defaults", configFile)
+		return
+	}
+
 	if err := viper.ReadInConfig(); err != nil {
 		log.Fatal().Err(err).Msg("Error reading config file")
 	}
+	setProfileDefaults()
+	bindEnv() // again, now that the custom profiles are known
+}
+
+// BuiltinIndexers are the indexers known without any [indexers.<name>] section.
+var BuiltinIndexers = []string{"redacted", "ops"}
+
+// IndexerNames returns the built-in indexers followed by any custom profiles from the config, sorted by name.
+func IndexerNames() []string {
+	var profiles []string
+	for name := range viper.GetStringMap("indexers") {
+		if !IsBuiltinIndexer(name) {
+			profiles = append(profiles, name)
+		}
+	}
+	sort.Strings(profiles)
+	return append(append([]string{}, BuiltinIndexers...), profiles...)
+}
+
+// paths served by redactedhook itself, which a route cannot take over.
+var reservedRouteNames = []string{"hook", "healthz", "metrics", "cache", "evaluate", "version"}
+
+var routeNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// RouteNames returns the names of the configured routes, sorted.
+func RouteNames() []string {
+	names := make([]string, 0, len(config.Routes))
+	for name := range config.Routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checks if the name is a built-in indexer or a configured profile.
+func isIndexerName(name string) bool {
+	for _, indexer := range IndexerNames() {
+		if name == indexer {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBuiltinIndexer checks if the indexer has a built-in endpoint, API key and user ID setting.
+func IsBuiltinIndexer(name string) bool {
+	for _, builtin := range BuiltinIndexers {
+		if name == builtin {
+			return true
+		}
+	}
+	return false
+}
+
+// checks if the action is one of the API actions whose responses are cached.
+func isCachedAction(action string) bool {
+	for _, cachedAction := range []string{"torrent", "torrentgroup", "user", "collage", "artist"} {
+		if action == cachedAction {
+			return true
+		}
+	}
+	return false
+}
+
+// checks if the flag is one of FeaturedFlags.
+func isFeaturedFlag(flag string) bool {
+	return isOneOf(FeaturedFlags, flag)
+}
+
+// checks if value is one of values.
+func isOneOf(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checks if the role is one of ArtistRoles, matched case-insensitively.
+func isArtistRole(role string) bool {
+	for _, artistRole := range ArtistRoles {
+		if strings.EqualFold(artistRole, strings.TrimSpace(role)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fills in the defaults for custom indexer profiles, which are only known once the config file is read.
+func setProfileDefaults() {
+	for _, name := range IndexerNames()[len(BuiltinIndexers):] {
+		viper.SetDefault("indexers."+name+".timeout", 10)
+		viper.SetDefault("indexers."+name+".rate_limit", 5)
+		viper.SetDefault("indexers."+name+".burst", 5)
+		viper.SetDefault("indexers."+name+".breaker_threshold", 5)
+		viper.SetDefault("indexers."+name+".breaker_cooldown", 30)
+		viper.SetDefault("indexers."+name+".adaptive_min_rate_limit", 1)
+	}
+}
+
+// binds an environment variable to every key of the config, as keys without a default are otherwise
+// unknown to viper and skipped by Unmarshal.
+func bindEnv() {
+	bindEnvKeys(reflect.TypeOf(Config{}), "")
+}
+
+// binds the keys of the struct fields under prefix, recursing into sections. the fields of custom profiles
+// are bound for the profiles in the config file, so a profile can't be defined by environment variables
+// alone. other tables, like routes, rule groups or scoring rules, can only be set in the config file.
+func bindEnvKeys(structType reflect.Type, prefix string) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" {
+			continue // derived from other fields, e.g. ParsedReleaseName
+		}
+		key := prefix + name
+
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			bindEnvKeys(field.Type, key+".")
+		case key == "indexers":
+			for _, indexer := range IndexerNames() {
+				bindEnvKeys(field.Type.Elem(), key+"."+indexer+".")
+			}
+		case field.Type.Kind() == reflect.Map, field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct:
+			continue
+		default:
+			_ = viper.BindEnv(key)
+		}
+	}
 }
 
 func readAndUnmarshalConfig() {
@@ -49,18 +304,83 @@ func readAndUnmarshalConfig() {
 		log.Error().Err(err).Msg("Unable to unmarshal config")
 	} else {
 		parseSizeCheck()
-		log.Debug().Msgf("Config file read: %s", viper.ConfigFileUsed())
+		parseReleaseNamePatterns()
+		if fileExists(viper.ConfigFileUsed()) {
+			log.Debug().Msgf("Config file read: %s", viper.ConfigFileUsed())
+		}
 		configureLogger()
 	}
 }
 
+// compiles the regexes, returning an error naming the first invalid one.
+func CompilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// ParseNetworks parses IPs and CIDRs, e.g. "192.168.1.0/24". a bare IP matches only itself.
+func ParseNetworks(entries []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP or CIDR", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP or CIDR", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// compiles the release name and remaster title regexes once, so they are not recompiled for every webhook.
+func parseReleaseNamePatterns() {
+	mustMatch, err := CompilePatterns(config.ReleaseName.MustMatch)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid release_name.must_match pattern; unable to compile")
+	}
+	mustNotMatch, err := CompilePatterns(config.ReleaseName.MustNotMatch)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid release_name.must_not_match pattern; unable to compile")
+	}
+	config.ParsedReleaseName = ParsedReleaseName{MustMatch: mustMatch, MustNotMatch: mustNotMatch}
+
+	titleMustMatch, err := CompilePatterns(config.Remaster.TitleMustMatch)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid remaster.title_must_match pattern; unable to compile")
+	}
+	titleMustNotMatch, err := CompilePatterns(config.Remaster.TitleMustNotMatch)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid remaster.title_must_not_match pattern; unable to compile")
+	}
+	config.ParsedRemaster = ParsedRemaster{TitleMustMatch: titleMustMatch, TitleMustNotMatch: titleMustNotMatch}
+}
+
 func parseSizeCheck() {
 	// Parse MinSize
 	minSizeStr := viper.GetString("sizecheck.minsize")
 	if minSizeStr == "" {
 		config.ParsedSizes.MinSize = 0 // Reset to default when empty string is provided
 	} else {
-		minSize, err := bytesize.Parse(minSizeStr)
+		minSize, err := ParseSize(minSizeStr)
 		if err != nil {
 			log.Error().Err(err).Msg("Invalid format for MinSize; unable to parse")
 		} else {
@@ -73,7 +393,7 @@ func parseSizeCheck() {
 	if maxSizeStr == "" {
 		config.ParsedSizes.MaxSize = 0 // Reset to default when empty string is provided
 	} else {
-		maxSize, err := bytesize.Parse(maxSizeStr)
+		maxSize, err := ParseSize(maxSizeStr)
 		if err != nil {
 			log.Error().Err(err).Msg("Invalid format for MaxSize; unable to parse")
 		} else {
@@ -82,7 +402,21 @@ func parseSizeCheck() {
 	}
 }
 
+// ParseSize parses a human readable size such as "500MB" or "1.5GiB".
+// Both the tracker style suffixes (KB, MB, GB, TB) and the IEC suffixes (KiB, MiB, GiB, TiB) are 1024-based.
+func ParseSize(s string) (bytesize.ByteSize, error) {
+	s = strings.TrimSpace(s)
+	if trimmed := strings.TrimSuffix(strings.TrimSuffix(s, "iB"), "IB"); trimmed != s {
+		s = trimmed + "B"
+	}
+	return bytesize.Parse(s)
+}
+
 func watchConfigChanges() {
+	if viper.ConfigFileUsed() == "" || !fileExists(viper.ConfigFileUsed()) {
+		return // configured through environment variables only, nothing to watch
+	}
+
 	viper.WatchConfig()
 	viper.OnConfigChange(func(e fsnotify.Event) {
 
@@ -92,16 +426,35 @@ func watchConfigChanges() {
 			log.Error().Err(err).Msg("Error reading config")
 			return
 		}
+		setProfileDefaults()
+		bindEnv()
+		config.Indexers = nil // unmarshal into a fresh map so oldConfig keeps its own copy
+		config.Authorization.AllowedIPs = nil
+		config.Authorization.TrustedProxies = nil
+		config.ReleaseName = ReleaseName{}
+		config.Remaster = Remaster{}
+		config.ReleaseTypes = ReleaseTypes{}
+		config.Tags = Tags{}
+		config.Artists = Artists{}
+		config.Audio = Audio{}
+		config.GroupIDs = GroupIDs{}
+		config.BestInGroup = BestInGroup{}
+		config.Scoring = Scoring{}
+		config.RuleGroups = nil
+		config.Routes = nil
+		config.Cache.ActionTTL = nil
 		if err := viper.Unmarshal(&config); err != nil {
 			log.Error().Err(err).Msg("Error unmarshalling config")
 			return
 		}
 
 		parseSizeCheck()
+		parseReleaseNamePatterns()
 
 		logConfigChanges(oldConfig, config)
 
-		if oldConfig.Logs.LogLevel != config.Logs.LogLevel {
+		// any of the logs settings can change the writers, so the logger is rebuilt when one of them does
+		if oldConfig.Logs != config.Logs {
 			configureLogger()
 		}
 		log.Debug().Msgf("Config file updated: %s", e.Name)
@@ -110,6 +463,19 @@ func watchConfigChanges() {
 
 func logConfigChanges(oldConfig, newConfig Config) {
 
+	if oldConfig.Authorization.HMACSecret != newConfig.Authorization.HMACSecret { // Authorization
+		log.Debug().Msg("hmac_secret changed")
+	}
+	if oldConfig.Authorization.WebhookToken != newConfig.Authorization.WebhookToken {
+		log.Debug().Msg("webhook_token changed")
+	}
+	if strings.Join(oldConfig.Authorization.AllowedIPs, ",") != strings.Join(newConfig.Authorization.AllowedIPs, ",") {
+		log.Debug().Msgf("AllowedIPs changed from %q to %q", oldConfig.Authorization.AllowedIPs, newConfig.Authorization.AllowedIPs)
+	}
+	if strings.Join(oldConfig.Authorization.TrustedProxies, ",") != strings.Join(newConfig.Authorization.TrustedProxies, ",") {
+		log.Debug().Msgf("TrustedProxies changed from %q to %q", oldConfig.Authorization.TrustedProxies, newConfig.Authorization.TrustedProxies)
+	}
+
 	if oldConfig.IndexerKeys.REDKey != newConfig.IndexerKeys.REDKey { // IndexerKeys
 		log.Debug().Msg("red_apikey changed")
 	}
@@ -127,15 +493,18 @@ func logConfigChanges(oldConfig, newConfig Config) {
 	if oldConfig.Ratio.MinRatio != newConfig.Ratio.MinRatio { // Ratio
 		log.Debug().Msgf("MinRatio changed from %f to %f", oldConfig.Ratio.MinRatio, newConfig.Ratio.MinRatio)
 	}
+	if oldConfig.Ratio.MinRatioBuffer != newConfig.Ratio.MinRatioBuffer {
+		log.Debug().Msgf("MinRatioBuffer changed from %s to %s", oldConfig.Ratio.MinRatioBuffer, newConfig.Ratio.MinRatioBuffer)
+	}
 
-	oldMinSize, _ := bytesize.Parse(oldConfig.SizeCheck.MinSize)
-	newMinSize, _ := bytesize.Parse(newConfig.SizeCheck.MinSize)
+	oldMinSize, _ := ParseSize(oldConfig.SizeCheck.MinSize)
+	newMinSize, _ := ParseSize(newConfig.SizeCheck.MinSize)
 	if oldMinSize != newMinSize { // SizeCheck
 		log.Debug().Msgf("MinSize changed from %s to %s", oldConfig.SizeCheck.MinSize, newConfig.SizeCheck.MinSize)
 	}
 
-	oldMaxSize, _ := bytesize.Parse(oldConfig.SizeCheck.MaxSize)
-	newMaxSize, _ := bytesize.Parse(newConfig.SizeCheck.MaxSize)
+	oldMaxSize, _ := ParseSize(oldConfig.SizeCheck.MaxSize)
+	newMaxSize, _ := ParseSize(newConfig.SizeCheck.MaxSize)
 	if oldMaxSize != newMaxSize { // SizeCheck
 		log.Debug().Msgf("MaxSize changed from %s to %s", oldConfig.SizeCheck.MaxSize, newConfig.SizeCheck.MaxSize)
 	}
@@ -146,13 +515,300 @@ func logConfigChanges(oldConfig, newConfig Config) {
 	if oldConfig.Uploaders.Mode != newConfig.Uploaders.Mode { // Uploaders
 		log.Debug().Msgf("Uploader mode changed from %s to %s", oldConfig.Uploaders.Mode, newConfig.Uploaders.Mode)
 	}
+	if oldConfig.Uploaders.Allow != newConfig.Uploaders.Allow {
+		log.Debug().Msgf("Allowed uploaders changed from %s to %s", oldConfig.Uploaders.Allow, newConfig.Uploaders.Allow)
+	}
+	if oldConfig.Uploaders.Block != newConfig.Uploaders.Block {
+		log.Debug().Msgf("Blocked uploaders changed from %s to %s", oldConfig.Uploaders.Block, newConfig.Uploaders.Block)
+	}
+
+	if oldConfig.RecordLabels.RecordLabels != newConfig.RecordLabels.RecordLabels { // RecordLabels
+		log.Debug().Msgf("RecordLabels changed from %s to %s", oldConfig.RecordLabels.RecordLabels, newConfig.RecordLabels.RecordLabels)
+	}
+	if oldConfig.RecordLabels.BlockedRecordLabels != newConfig.RecordLabels.BlockedRecordLabels {
+		log.Debug().Msgf("BlockedRecordLabels changed from %s to %s", oldConfig.RecordLabels.BlockedRecordLabels, newConfig.RecordLabels.BlockedRecordLabels)
+	}
+
+	if oldConfig.LogScore.MinLogScore != newConfig.LogScore.MinLogScore { // LogScore
+		log.Debug().Msgf("MinLogScore changed from %d to %d", oldConfig.LogScore.MinLogScore, newConfig.LogScore.MinLogScore)
+	}
+	if oldConfig.LogScore.Strict != newConfig.LogScore.Strict {
+		log.Debug().Msgf("LogScore strict changed from %t to %t", oldConfig.LogScore.Strict, newConfig.LogScore.Strict)
+	}
+	if oldConfig.LogScore.RequireLog != newConfig.LogScore.RequireLog {
+		log.Debug().Msgf("RequireLog changed from %t to %t", oldConfig.LogScore.RequireLog, newConfig.LogScore.RequireLog)
+	}
+	if oldConfig.LogScore.RequireCue != newConfig.LogScore.RequireCue {
+		log.Debug().Msgf("RequireCue changed from %t to %t", oldConfig.LogScore.RequireCue, newConfig.LogScore.RequireCue)
+	}
+
+	if oldConfig.Freeleech.FreeleechOnly != newConfig.Freeleech.FreeleechOnly { // Freeleech
+		log.Debug().Msgf("FreeleechOnly changed from %t to %t", oldConfig.Freeleech.FreeleechOnly, newConfig.Freeleech.FreeleechOnly)
+	}
+	if oldConfig.Freeleech.SkipFreeleech != newConfig.Freeleech.SkipFreeleech {
+		log.Debug().Msgf("SkipFreeleech changed from %t to %t", oldConfig.Freeleech.SkipFreeleech, newConfig.Freeleech.SkipFreeleech)
+	}
+
+	if oldConfig.Year.MinYear != newConfig.Year.MinYear { // Year
+		log.Debug().Msgf("MinYear changed from %d to %d", oldConfig.Year.MinYear, newConfig.Year.MinYear)
+	}
+	if oldConfig.Year.MaxYear != newConfig.Year.MaxYear {
+		log.Debug().Msgf("MaxYear changed from %d to %d", oldConfig.Year.MaxYear, newConfig.Year.MaxYear)
+	}
+	if oldConfig.Year.AllowUnknown != newConfig.Year.AllowUnknown {
+		log.Debug().Msgf("Year AllowUnknown changed from %t to %t", oldConfig.Year.AllowUnknown, newConfig.Year.AllowUnknown)
+	}
+
+	if oldConfig.Format.Formats != newConfig.Format.Formats { // Format
+		log.Debug().Msgf("Formats changed from %s to %s", oldConfig.Format.Formats, newConfig.Format.Formats)
+	}
+	if oldConfig.Format.Encodings != newConfig.Format.Encodings {
+		log.Debug().Msgf("Encodings changed from %s to %s", oldConfig.Format.Encodings, newConfig.Format.Encodings)
+	}
+	if oldConfig.Format.EncodingPreference != newConfig.Format.EncodingPreference {
+		log.Debug().Msgf("EncodingPreference changed from %s to %s", oldConfig.Format.EncodingPreference, newConfig.Format.EncodingPreference)
+	}
+
+	if oldConfig.Media.Media != newConfig.Media.Media { // Media
+		log.Debug().Msgf("Media changed from %s to %s", oldConfig.Media.Media, newConfig.Media.Media)
+	}
+
+	if oldConfig.Seeders.MinSeeders != newConfig.Seeders.MinSeeders { // Seeders
+		log.Debug().Msgf("MinSeeders changed from %d to %d", oldConfig.Seeders.MinSeeders, newConfig.Seeders.MinSeeders)
+	}
+
+	if oldConfig.Leechers.MinLeechers != newConfig.Leechers.MinLeechers { // Leechers
+		log.Debug().Msgf("MinLeechers changed from %d to %d", oldConfig.Leechers.MinLeechers, newConfig.Leechers.MinLeechers)
+	}
+
+	if oldConfig.Snatched.MinSnatched != newConfig.Snatched.MinSnatched { // Snatched
+		log.Debug().Msgf("MinSnatched changed from %d to %d", oldConfig.Snatched.MinSnatched, newConfig.Snatched.MinSnatched)
+	}
+
+	if strings.Join(oldConfig.ReleaseName.MustMatch, "\n") != strings.Join(newConfig.ReleaseName.MustMatch, "\n") { // ReleaseName
+		log.Debug().Msgf("Release name must_match changed from %q to %q", oldConfig.ReleaseName.MustMatch, newConfig.ReleaseName.MustMatch)
+	}
+	if strings.Join(oldConfig.ReleaseName.MustNotMatch, "\n") != strings.Join(newConfig.ReleaseName.MustNotMatch, "\n") {
+		log.Debug().Msgf("Release name must_not_match changed from %q to %q", oldConfig.ReleaseName.MustNotMatch, newConfig.ReleaseName.MustNotMatch)
+	}
+
+	if strings.Join(oldConfig.Remaster.TitleMustMatch, "\n") != strings.Join(newConfig.Remaster.TitleMustMatch, "\n") { // Remaster
+		log.Debug().Msgf("Remaster title_must_match changed from %q to %q", oldConfig.Remaster.TitleMustMatch, newConfig.Remaster.TitleMustMatch)
+	}
+	if strings.Join(oldConfig.Remaster.TitleMustNotMatch, "\n") != strings.Join(newConfig.Remaster.TitleMustNotMatch, "\n") {
+		log.Debug().Msgf("Remaster title_must_not_match changed from %q to %q", oldConfig.Remaster.TitleMustNotMatch, newConfig.Remaster.TitleMustNotMatch)
+	}
+	if fmt.Sprint(oldConfig.Remaster.Years) != fmt.Sprint(newConfig.Remaster.Years) {
+		log.Debug().Msgf("Remaster years changed from %v to %v", oldConfig.Remaster.Years, newConfig.Remaster.Years)
+	}
+
+	if oldConfig.Scene.SceneOnly != newConfig.Scene.SceneOnly { // Scene
+		log.Debug().Msgf("SceneOnly changed from %t to %t", oldConfig.Scene.SceneOnly, newConfig.Scene.SceneOnly)
+	}
+	if oldConfig.Scene.SkipScene != newConfig.Scene.SkipScene {
+		log.Debug().Msgf("SkipScene changed from %t to %t", oldConfig.Scene.SkipScene, newConfig.Scene.SkipScene)
+	}
+
+	if oldConfig.Trumpable.SkipTrumpable != newConfig.Trumpable.SkipTrumpable { // Trumpable
+		log.Debug().Msgf("SkipTrumpable changed from %t to %t", oldConfig.Trumpable.SkipTrumpable, newConfig.Trumpable.SkipTrumpable)
+	}
+
+	if oldConfig.Featured.RequireFeatured != newConfig.Featured.RequireFeatured { // Featured
+		log.Debug().Msgf("RequireFeatured changed from %t to %t", oldConfig.Featured.RequireFeatured, newConfig.Featured.RequireFeatured)
+	}
+	if oldConfig.Featured.SkipFeatured != newConfig.Featured.SkipFeatured {
+		log.Debug().Msgf("SkipFeatured changed from %t to %t", oldConfig.Featured.SkipFeatured, newConfig.Featured.SkipFeatured)
+	}
+
+	if oldConfig.Catalogue.CatalogueNumbers != newConfig.Catalogue.CatalogueNumbers { // Catalogue
+		log.Debug().Msgf("CatalogueNumbers changed from %s to %s", oldConfig.Catalogue.CatalogueNumbers, newConfig.Catalogue.CatalogueNumbers)
+	}
+
+	if strings.Join(oldConfig.ReleaseTypes.ReleaseTypes, ",") != strings.Join(newConfig.ReleaseTypes.ReleaseTypes, ",") { // ReleaseTypes
+		log.Debug().Msgf("ReleaseTypes changed from %s to %s", oldConfig.ReleaseTypes.ReleaseTypes, newConfig.ReleaseTypes.ReleaseTypes)
+	}
+
+	if strings.Join(oldConfig.Tags.RequireAny, ",") != strings.Join(newConfig.Tags.RequireAny, ",") { // Tags
+		log.Debug().Msgf("Tags.RequireAny changed from %s to %s", oldConfig.Tags.RequireAny, newConfig.Tags.RequireAny)
+	}
+	if strings.Join(oldConfig.Tags.Exclude, ",") != strings.Join(newConfig.Tags.Exclude, ",") {
+		log.Debug().Msgf("Tags.Exclude changed from %s to %s", oldConfig.Tags.Exclude, newConfig.Tags.Exclude)
+	}
+
+	if oldConfig.Age.MaxAge != newConfig.Age.MaxAge { // Age
+		log.Debug().Msgf("MaxAge changed from %s to %s", oldConfig.Age.MaxAge, newConfig.Age.MaxAge)
+	}
+
+	if oldConfig.Scoring.MinScore != newConfig.Scoring.MinScore { // Scoring
+		log.Debug().Msgf("MinScore changed from %d to %d", oldConfig.Scoring.MinScore, newConfig.Scoring.MinScore)
+	}
+	if fmt.Sprint(oldConfig.Scoring.Rules) != fmt.Sprint(newConfig.Scoring.Rules) {
+		log.Debug().Msgf("Scoring rules changed from %v to %v", oldConfig.Scoring.Rules, newConfig.Scoring.Rules)
+	}
+	if fmt.Sprint(oldConfig.RuleGroups) != fmt.Sprint(newConfig.RuleGroups) { // RuleGroups
+		log.Debug().Msgf("RuleGroups changed from %v to %v", oldConfig.RuleGroups, newConfig.RuleGroups)
+	}
+
+	if fmt.Sprint(oldConfig.Routes) != fmt.Sprint(newConfig.Routes) { // Routes
+		log.Debug().Msgf("Routes changed from %v to %v (new or removed routes require a restart)", oldConfig.Routes, newConfig.Routes)
+	}
+
+	if strings.Join(oldConfig.Artists.RequireArtist, ",") != strings.Join(newConfig.Artists.RequireArtist, ",") { // Artists
+		log.Debug().Msgf("Artists.RequireArtist changed from %s to %s", oldConfig.Artists.RequireArtist, newConfig.Artists.RequireArtist)
+	}
+	if strings.Join(oldConfig.Artists.Roles, ",") != strings.Join(newConfig.Artists.Roles, ",") {
+		log.Debug().Msgf("Artists.Roles changed from %s to %s", oldConfig.Artists.Roles, newConfig.Artists.Roles)
+	}
+
+	if oldConfig.Audio.MinBitDepth != newConfig.Audio.MinBitDepth { // Audio
+		log.Debug().Msgf("Audio.MinBitDepth changed from %d to %d", oldConfig.Audio.MinBitDepth, newConfig.Audio.MinBitDepth)
+	}
+	if fmt.Sprint(oldConfig.Audio.AllowedSampleRates) != fmt.Sprint(newConfig.Audio.AllowedSampleRates) {
+		log.Debug().Msgf("Audio.AllowedSampleRates changed from %v to %v", oldConfig.Audio.AllowedSampleRates, newConfig.Audio.AllowedSampleRates)
+	}
+	if oldConfig.Audio.AllowUnknown != newConfig.Audio.AllowUnknown {
+		log.Debug().Msgf("Audio.AllowUnknown changed from %t to %t", oldConfig.Audio.AllowUnknown, newConfig.Audio.AllowUnknown)
+	}
+
+	if fmt.Sprint(oldConfig.GroupIDs.Allow) != fmt.Sprint(newConfig.GroupIDs.Allow) { // GroupIDs
+		log.Debug().Msgf("GroupIDs.Allow changed from %v to %v", oldConfig.GroupIDs.Allow, newConfig.GroupIDs.Allow)
+	}
+	if fmt.Sprint(oldConfig.GroupIDs.Block) != fmt.Sprint(newConfig.GroupIDs.Block) {
+		log.Debug().Msgf("GroupIDs.Block changed from %v to %v", oldConfig.GroupIDs.Block, newConfig.GroupIDs.Block)
+	}
+
+	if oldConfig.BestInGroup.Enabled != newConfig.BestInGroup.Enabled { // BestInGroup
+		log.Debug().Msgf("BestInGroup.Enabled changed from %t to %t", oldConfig.BestInGroup.Enabled, newConfig.BestInGroup.Enabled)
+	}
+	if strings.Join(oldConfig.BestInGroup.Preference, ",") != strings.Join(newConfig.BestInGroup.Preference, ",") {
+		log.Debug().Msgf("BestInGroup.Preference changed from %q to %q", oldConfig.BestInGroup.Preference, newConfig.BestInGroup.Preference)
+	}
+
+	if oldConfig.Files.MinFiles != newConfig.Files.MinFiles { // Files
+		log.Debug().Msgf("MinFiles changed from %d to %d", oldConfig.Files.MinFiles, newConfig.Files.MinFiles)
+	}
+	if oldConfig.Files.MaxFiles != newConfig.Files.MaxFiles {
+		log.Debug().Msgf("MaxFiles changed from %d to %d", oldConfig.Files.MaxFiles, newConfig.Files.MaxFiles)
+	}
+
+	for name, newIndexer := range newConfig.Indexers { // Indexers
+		oldIndexer := oldConfig.Indexers[name]
+		if oldIndexer.Timeout != newIndexer.Timeout {
+			log.Debug().Msgf("[%s] Timeout changed from %d to %d", name, oldIndexer.Timeout, newIndexer.Timeout)
+		}
+		if oldIndexer.RateLimit != newIndexer.RateLimit {
+			log.Debug().Msgf("[%s] RateLimit changed from %d to %d", name, oldIndexer.RateLimit, newIndexer.RateLimit)
+		}
+		if oldIndexer.Burst != newIndexer.Burst {
+			log.Debug().Msgf("[%s] Burst changed from %d to %d", name, oldIndexer.Burst, newIndexer.Burst)
+		}
+		if oldIndexer.SmoothBurst != newIndexer.SmoothBurst {
+			log.Debug().Msgf("[%s] SmoothBurst changed from %t to %t", name, oldIndexer.SmoothBurst, newIndexer.SmoothBurst)
+		}
+		if oldIndexer.APIBase != newIndexer.APIBase {
+			log.Debug().Msgf("[%s] APIBase changed from %s to %s", name, oldIndexer.APIBase, newIndexer.APIBase)
+		}
+		if oldIndexer.APIKey != newIndexer.APIKey {
+			log.Debug().Msgf("[%s] api_key changed", name)
+		}
+		if oldIndexer.AuthHeader != newIndexer.AuthHeader || oldIndexer.AuthFormat != newIndexer.AuthFormat {
+			log.Debug().Msgf("[%s] Auth header changed from %s: %s to %s: %s", name, oldIndexer.AuthHeader, oldIndexer.AuthFormat, newIndexer.AuthHeader, newIndexer.AuthFormat)
+		}
+		if oldIndexer.UserID != newIndexer.UserID {
+			log.Debug().Msgf("[%s] UserID changed from %d to %d", name, oldIndexer.UserID, newIndexer.UserID)
+		}
+		if oldIndexer.BreakerThreshold != newIndexer.BreakerThreshold {
+			log.Debug().Msgf("[%s] BreakerThreshold changed from %d to %d", name, oldIndexer.BreakerThreshold, newIndexer.BreakerThreshold)
+		}
+		if oldIndexer.BreakerCooldown != newIndexer.BreakerCooldown {
+			log.Debug().Msgf("[%s] BreakerCooldown changed from %d to %d", name, oldIndexer.BreakerCooldown, newIndexer.BreakerCooldown)
+		}
+		if oldIndexer.AdaptiveLatency != newIndexer.AdaptiveLatency || oldIndexer.AdaptiveMinRateLimit != newIndexer.AdaptiveMinRateLimit {
+			log.Debug().Msgf("[%s] Adaptive rate limit changed from %dms (min %d) to %dms (min %d)", name, oldIndexer.AdaptiveLatency, oldIndexer.AdaptiveMinRateLimit, newIndexer.AdaptiveLatency, newIndexer.AdaptiveMinRateLimit)
+		}
+		if oldIndexer.FallbackIndexer != newIndexer.FallbackIndexer {
+			log.Debug().Msgf("[%s] FallbackIndexer changed from %q to %q", name, oldIndexer.FallbackIndexer, newIndexer.FallbackIndexer)
+		}
+	}
+
+	if oldConfig.HTTPClient.MaxAttempts != newConfig.HTTPClient.MaxAttempts { // HTTPClient
+		log.Debug().Msgf("MaxAttempts changed from %d to %d", oldConfig.HTTPClient.MaxAttempts, newConfig.HTTPClient.MaxAttempts)
+	}
+	if oldConfig.HTTPClient.RetryDelay != newConfig.HTTPClient.RetryDelay {
+		log.Debug().Msgf("RetryDelay changed from %d to %d", oldConfig.HTTPClient.RetryDelay, newConfig.HTTPClient.RetryDelay)
+	}
+	if oldConfig.HTTPClient.MaxBodySize != newConfig.HTTPClient.MaxBodySize {
+		log.Debug().Msgf("MaxBodySize changed from %d to %d", oldConfig.HTTPClient.MaxBodySize, newConfig.HTTPClient.MaxBodySize)
+	}
+	if oldConfig.HTTPClient.UserAgent != newConfig.HTTPClient.UserAgent {
+		log.Debug().Msgf("UserAgent changed from %q to %q", oldConfig.HTTPClient.UserAgent, newConfig.HTTPClient.UserAgent)
+	}
+
+	if oldConfig.Cache.TTL != newConfig.Cache.TTL { // Cache
+		log.Debug().Msgf("Cache TTL changed from %d to %d", oldConfig.Cache.TTL, newConfig.Cache.TTL)
+	}
+	if fmt.Sprint(oldConfig.Cache.ActionTTL) != fmt.Sprint(newConfig.Cache.ActionTTL) {
+		log.Debug().Msgf("Cache ActionTTL changed from %v to %v", oldConfig.Cache.ActionTTL, newConfig.Cache.ActionTTL)
+	}
+	if oldConfig.Cache.TTLJitter != newConfig.Cache.TTLJitter {
+		log.Debug().Msgf("Cache TTLJitter changed from %d to %d", oldConfig.Cache.TTLJitter, newConfig.Cache.TTLJitter)
+	}
+	if oldConfig.Cache.NegativeTTL != newConfig.Cache.NegativeTTL {
+		log.Debug().Msgf("Cache NegativeTTL changed from %d to %d", oldConfig.Cache.NegativeTTL, newConfig.Cache.NegativeTTL)
+	}
+	if oldConfig.Cache.MaxEntries != newConfig.Cache.MaxEntries {
+		log.Debug().Msgf("Cache MaxEntries changed from %d to %d", oldConfig.Cache.MaxEntries, newConfig.Cache.MaxEntries)
+	}
+	if oldConfig.Cache.PersistPath != newConfig.Cache.PersistPath {
+		log.Debug().Msgf("Cache PersistPath changed from %s to %s", oldConfig.Cache.PersistPath, newConfig.Cache.PersistPath)
+	}
+	if oldConfig.Cache.ResolveHashes != newConfig.Cache.ResolveHashes {
+		log.Debug().Msgf("Cache ResolveHashes changed from %t to %t", oldConfig.Cache.ResolveHashes, newConfig.Cache.ResolveHashes)
+	}
+
+	if oldConfig.Server.Address != newConfig.Server.Address || oldConfig.Server.Port != newConfig.Server.Port { // Server
+		log.Debug().Msg("Listen address changed, restart to apply")
+	}
+	if oldConfig.Server.ShutdownTimeout != newConfig.Server.ShutdownTimeout {
+		log.Debug().Msgf("ShutdownTimeout changed from %d to %d", oldConfig.Server.ShutdownTimeout, newConfig.Server.ShutdownTimeout)
+	}
+	if oldConfig.Server.DryRun != newConfig.Server.DryRun {
+		log.Debug().Msgf("DryRun changed from %t to %t", oldConfig.Server.DryRun, newConfig.Server.DryRun)
+	}
+	if oldConfig.Server.RejectStatus != newConfig.Server.RejectStatus {
+		log.Debug().Msgf("RejectStatus changed from %d to %d", oldConfig.Server.RejectStatus, newConfig.Server.RejectStatus)
+	}
+	if oldConfig.Server.IncludeEvaluated != newConfig.Server.IncludeEvaluated {
+		log.Debug().Msgf("IncludeEvaluated changed from %t to %t", oldConfig.Server.IncludeEvaluated, newConfig.Server.IncludeEvaluated)
+	}
+	if oldConfig.Server.ClientRateLimit != newConfig.Server.ClientRateLimit || oldConfig.Server.GlobalRateLimit != newConfig.Server.GlobalRateLimit {
+		log.Debug().Msgf("Ingress rate limits changed from %d/%d to %d/%d requests per minute (client/global)", oldConfig.Server.ClientRateLimit, oldConfig.Server.GlobalRateLimit, newConfig.Server.ClientRateLimit, newConfig.Server.GlobalRateLimit)
+	}
+	if oldConfig.Server.DebugHistory != newConfig.Server.DebugHistory {
+		log.Debug().Msgf("DebugHistory changed from %d to %d", oldConfig.Server.DebugHistory, newConfig.Server.DebugHistory)
+	}
+	if oldConfig.Server.OnAPIError != newConfig.Server.OnAPIError {
+		log.Debug().Msgf("OnAPIError changed from %s to %s", oldConfig.Server.OnAPIError, newConfig.Server.OnAPIError)
+		if newConfig.Server.OnAPIError == "approve" {
+			log.Warn().Msg("on_api_error is set to approve: torrents will be approved unchecked while the tracker is unreachable")
+		}
+	}
+	if oldConfig.Server.TLSCert != newConfig.Server.TLSCert || oldConfig.Server.TLSKey != newConfig.Server.TLSKey {
+		log.Debug().Msg("TLS certificate paths changed, restart to apply")
+	}
 
 	if oldConfig.Logs.LogLevel != newConfig.Logs.LogLevel { // Logs
 		log.Debug().Msgf("Log level changed from %s to %s", oldConfig.Logs.LogLevel, newConfig.Logs.LogLevel)
 	}
+	if oldConfig.Logs.LogFormat != newConfig.Logs.LogFormat {
+		log.Debug().Msgf("Log format changed from %s to %s, restart to apply", oldConfig.Logs.LogFormat, newConfig.Logs.LogFormat)
+	}
 	if oldConfig.Logs.LogToFile != newConfig.Logs.LogToFile { // Logs
 		log.Debug().Msgf("LogToFile changed from %t to %t", oldConfig.Logs.LogToFile, newConfig.Logs.LogToFile)
 	}
+	if oldConfig.Logs.LogToConsole != newConfig.Logs.LogToConsole {
+		log.Debug().Msgf("LogToConsole changed from %t to %t, restart to apply", oldConfig.Logs.LogToConsole, newConfig.Logs.LogToConsole)
+	}
 	if oldConfig.Logs.LogFilePath != newConfig.Logs.LogFilePath { // Logs
 		log.Debug().Msgf("LogFilePath changed from %s to %s", oldConfig.Logs.LogFilePath, newConfig.Logs.LogFilePath)
 	}
@@ -197,6 +853,29 @@ func ValidateConfig() error {
 	//	validationErrors = append(validationErrors, "Minimum ratio should be positive")
 	//}
 
+	if minRatioBuffer := viper.GetString("ratio.min_ratio_buffer"); minRatioBuffer != "" {
+		if _, err := ParseSize(minRatioBuffer); err != nil {
+			validationErrors = append(validationErrors, "Invalid minimum ratio buffer: "+minRatioBuffer)
+		}
+	}
+
+	var minSize, maxSize bytesize.ByteSize
+	if minSizeStr := viper.GetString("sizecheck.minsize"); minSizeStr != "" {
+		var err error
+		if minSize, err = ParseSize(minSizeStr); err != nil {
+			validationErrors = append(validationErrors, "Invalid minimum size: "+minSizeStr)
+		}
+	}
+	if maxSizeStr := viper.GetString("sizecheck.maxsize"); maxSizeStr != "" {
+		var err error
+		if maxSize, err = ParseSize(maxSizeStr); err != nil {
+			validationErrors = append(validationErrors, "Invalid maximum size: "+maxSizeStr)
+		}
+	}
+	if maxSize > 0 && minSize > maxSize {
+		validationErrors = append(validationErrors, "Minimum size should not be greater than maximum size")
+	}
+
 	//if viper.IsSet("sizecheck.minsize") && viper.GetString("sizecheck.minsize") == "" {
 	//	validationErrors = append(validationErrors, "Invalid minimum size")
 	//}
@@ -217,10 +896,243 @@ func ValidateConfig() error {
 	//	validationErrors = append(validationErrors, "Invalid record_labels set")
 	//}
 
+	if minLogScore := viper.GetInt("log_score.min_log_score"); minLogScore < 0 || minLogScore > 100 {
+		validationErrors = append(validationErrors, "Minimum log score should be between 0 and 100")
+	}
+
+	if viper.GetBool("freeleech.freeleech_only") && viper.GetBool("freeleech.skip_freeleech") {
+		validationErrors = append(validationErrors, "freeleech_only and skip_freeleech cannot both be enabled")
+	}
+
+	if minYear, maxYear := viper.GetInt("year.min_year"), viper.GetInt("year.max_year"); minYear < 0 || maxYear < 0 || (maxYear > 0 && minYear > maxYear) {
+		validationErrors = append(validationErrors, "Invalid year range")
+	}
+
+	if viper.GetInt("seeders.min_seeders") < 0 {
+		validationErrors = append(validationErrors, "Minimum seeders should be a non-negative integer")
+	}
+
+	if viper.GetInt("leechers.min_leechers") < 0 {
+		validationErrors = append(validationErrors, "Minimum leechers should be a non-negative integer")
+	}
+
+	if viper.GetInt("snatched.min_snatched") < 0 {
+		validationErrors = append(validationErrors, "Minimum snatched should be a non-negative integer")
+	}
+
+	for _, key := range []string{"authorization.allowed_ips", "authorization.trusted_proxies"} {
+		if _, err := ParseNetworks(viper.GetStringSlice(key)); err != nil {
+			validationErrors = append(validationErrors, "Invalid "+key+" entry: "+err.Error())
+		}
+	}
+
+	for _, key := range []string{"release_name.must_match", "release_name.must_not_match", "remaster.title_must_match", "remaster.title_must_not_match"} {
+		if _, err := CompilePatterns(viper.GetStringSlice(key)); err != nil {
+			validationErrors = append(validationErrors, "Invalid "+key+" pattern: "+err.Error())
+		}
+	}
+
+	if viper.GetBool("scene.scene_only") && viper.GetBool("scene.skip_scene") {
+		validationErrors = append(validationErrors, "scene_only and skip_scene cannot both be enabled")
+	}
+
+	if viper.GetBool("featured.require_featured") && viper.GetBool("featured.skip_featured") {
+		validationErrors = append(validationErrors, "require_featured and skip_featured cannot both be enabled")
+	}
+
+	for _, releaseType := range viper.GetStringSlice("release_types.release_types") {
+		if _, ok := ReleaseTypeCode(releaseType); !ok {
+			validationErrors = append(validationErrors, "Unknown release type: "+releaseType)
+		}
+	}
+
+	if viper.GetInt("audio.min_bit_depth") < 0 {
+		validationErrors = append(validationErrors, "Minimum bit depth should be a non-negative integer")
+	}
+
+	for _, role := range viper.GetStringSlice("artists.roles") {
+		if !isArtistRole(role) {
+			validationErrors = append(validationErrors, "Unknown artist role: "+role)
+		}
+	}
+
+	if maxAge := viper.GetString("age.max_age"); maxAge != "" {
+		if duration, err := time.ParseDuration(maxAge); err != nil || duration < 0 {
+			validationErrors = append(validationErrors, "Max age should be a non-negative duration like \"1h\": "+maxAge)
+		}
+	}
+
+	if minFiles, maxFiles := viper.GetInt("files.min_files"), viper.GetInt("files.max_files"); minFiles < 0 || maxFiles < 0 || (maxFiles > 0 && minFiles > maxFiles) {
+		validationErrors = append(validationErrors, "Invalid file count range")
+	}
+
+	var scoreRules []ScoreRule
+	if err := viper.UnmarshalKey("scoring.rules", &scoreRules); err != nil {
+		validationErrors = append(validationErrors, "Scoring rules should be a list of tables with field, value, per and points: "+err.Error())
+	}
+	for i, rule := range scoreRules {
+		switch {
+		case isOneOf(ScoreTextFields, rule.Field):
+			if rule.Value == "" {
+				validationErrors = append(validationErrors, fmt.Sprintf("Scoring rule %d on %s should have a value", i+1, rule.Field))
+			}
+		case isOneOf(ScoreFlagFields, rule.Field):
+		case isOneOf(ScoreCountFields, rule.Field):
+			if rule.Per <= 0 {
+				validationErrors = append(validationErrors, fmt.Sprintf("Scoring rule %d on %s should have a positive per", i+1, rule.Field))
+			}
+		default:
+			validationErrors = append(validationErrors, fmt.Sprintf("Scoring rule %d has an unknown field: %s", i+1, rule.Field))
+		}
+	}
+
+	for name := range viper.GetStringMap("routes") {
+		if !routeNameRegex.MatchString(name) {
+			validationErrors = append(validationErrors, "Route "+name+" should only use lowercase letters, digits, dashes and underscores, as it is served at /"+name)
+		}
+		for _, reserved := range reservedRouteNames {
+			if name == reserved {
+				validationErrors = append(validationErrors, "Route "+name+" clashes with the built-in /"+name+" endpoint")
+			}
+		}
+	}
+
+	for _, name := range IndexerNames() {
+		if !IsBuiltinIndexer(name) && viper.GetString("indexers."+name+".api_base") == "" {
+			validationErrors = append(validationErrors, "API base for "+name+" is required for custom indexer profiles")
+		}
+		if viper.GetInt("indexers."+name+".timeout") <= 0 {
+			validationErrors = append(validationErrors, "Timeout for "+name+" should be a positive integer")
+		}
+		if viper.GetInt("indexers."+name+".rate_limit") <= 0 {
+			validationErrors = append(validationErrors, "Rate limit for "+name+" should be a positive integer")
+		}
+		if viper.GetInt("indexers."+name+".burst") <= 0 {
+			validationErrors = append(validationErrors, "Burst for "+name+" should be a positive integer")
+		}
+		if apiBase := viper.GetString("indexers." + name + ".api_base"); apiBase != "" {
+			if u, err := url.Parse(apiBase); err != nil || !u.IsAbs() || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+				validationErrors = append(validationErrors, "API base for "+name+" should be an absolute http(s) URL: "+apiBase)
+			}
+		}
+		if authFormat := viper.GetString("indexers." + name + ".auth_format"); authFormat != "" && !strings.Contains(authFormat, "{key}") {
+			validationErrors = append(validationErrors, "Auth format for "+name+" should contain {key}: "+authFormat)
+		}
+		if viper.GetInt("indexers."+name+".breaker_threshold") < 0 {
+			validationErrors = append(validationErrors, "Breaker threshold for "+name+" should be a non-negative integer")
+		}
+		if viper.GetInt("indexers."+name+".breaker_threshold") > 0 && viper.GetInt("indexers."+name+".breaker_cooldown") <= 0 {
+			validationErrors = append(validationErrors, "Breaker cooldown for "+name+" should be a positive integer")
+		}
+		if fallback := viper.GetString("indexers." + name + ".fallback_indexer"); fallback != "" && (fallback == name || !isIndexerName(fallback)) {
+			validationErrors = append(validationErrors, "Fallback indexer for "+name+" should be another configured indexer: "+fallback)
+		}
+		if flag := viper.GetString("indexers." + name + ".featured_flag"); flag != "" && !isFeaturedFlag(flag) {
+			validationErrors = append(validationErrors, "Featured flag for "+name+" should be one of "+strings.Join(FeaturedFlags, ", ")+": "+flag)
+		}
+		if viper.GetInt("indexers."+name+".adaptive_latency") < 0 {
+			validationErrors = append(validationErrors, "Adaptive latency for "+name+" should be a non-negative integer")
+		}
+		if minRateLimit := viper.GetInt("indexers." + name + ".adaptive_min_rate_limit"); minRateLimit <= 0 || minRateLimit > viper.GetInt("indexers."+name+".rate_limit") {
+			validationErrors = append(validationErrors, "Adaptive min rate limit for "+name+" should be between 1 and its rate limit")
+		}
+	}
+
+	if viper.GetInt("http_client.max_attempts") <= 0 {
+		validationErrors = append(validationErrors, "Max attempts should be a positive integer")
+	}
+
+	if viper.GetInt("http_client.retry_delay") < 0 {
+		validationErrors = append(validationErrors, "Retry delay should be a non-negative integer")
+	}
+
+	if viper.GetInt("http_client.max_concurrent_requests") < 0 {
+		validationErrors = append(validationErrors, "Max concurrent requests should be a non-negative integer")
+	}
+
+	if viper.GetInt("http_client.max_body_size") < 0 {
+		validationErrors = append(validationErrors, "Max body size should be a non-negative integer")
+	}
+
+	if viper.GetInt("http_client.max_idle_conns") < 0 || viper.GetInt("http_client.max_idle_conns_per_host") < 0 {
+		validationErrors = append(validationErrors, "Idle connection limits should be non-negative integers")
+	}
+
+	if viper.GetInt("http_client.idle_conn_timeout") < 0 {
+		validationErrors = append(validationErrors, "Idle connection timeout should be a non-negative integer")
+	}
+
+	if viper.GetInt("cache.ttl") < 0 {
+		validationErrors = append(validationErrors, "Cache TTL should be a non-negative integer")
+	}
+
+	for action := range viper.GetStringMap("cache.action_ttl") {
+		if !isCachedAction(action) {
+			validationErrors = append(validationErrors, "Unknown action in cache action_ttl: "+action)
+		}
+		if viper.GetInt("cache.action_ttl."+action) < 0 {
+			validationErrors = append(validationErrors, "Cache TTL for "+action+" should be a non-negative integer")
+		}
+	}
+
+	if jitter := viper.GetInt("cache.ttl_jitter"); jitter < 0 || jitter > 100 {
+		validationErrors = append(validationErrors, "Cache TTL jitter should be a percentage between 0 and 100")
+	}
+
+	if viper.GetInt("cache.negative_ttl") < 0 {
+		validationErrors = append(validationErrors, "Cache negative TTL should be a non-negative integer")
+	}
+
+	if viper.GetInt("cache.max_entries") <= 0 {
+		validationErrors = append(validationErrors, "Cache max entries should be a positive integer")
+	}
+
+	if listenAddr := viper.GetString("server.listen_addr"); listenAddr != "" {
+		if path, ok := strings.CutPrefix(listenAddr, "unix:"); ok {
+			if path == "" {
+				validationErrors = append(validationErrors, "listen_addr needs a socket path after unix:, e.g., unix:/run/redactedhook.sock")
+			}
+		} else if _, _, err := net.SplitHostPort(listenAddr); err != nil {
+			validationErrors = append(validationErrors, "listen_addr should be host:port or unix:/path/to/socket: "+err.Error())
+		}
+	}
+
+	if viper.GetInt("server.shutdown_timeout") < 0 {
+		validationErrors = append(validationErrors, "Shutdown timeout should be a non-negative integer")
+	}
+
+	if rejectStatus := viper.GetInt("server.reject_status"); rejectStatus != 0 && (rejectStatus < 100 || rejectStatus > 599 || (rejectStatus >= 200 && rejectStatus < 300)) {
+		validationErrors = append(validationErrors, "reject_status should be a non-2xx HTTP status code, e.g., 420")
+	}
+
+	if viper.GetInt("server.client_rate_limit") < 0 || viper.GetInt("server.global_rate_limit") < 0 {
+		validationErrors = append(validationErrors, "client_rate_limit and global_rate_limit should be non-negative integers")
+	}
+
+	if viper.GetInt("server.debug_history") < 0 {
+		validationErrors = append(validationErrors, "debug_history should be a non-negative integer")
+	}
+
+	if onAPIError := viper.GetString("server.on_api_error"); onAPIError != "reject" && onAPIError != "approve" {
+		validationErrors = append(validationErrors, "on_api_error should be either reject or approve")
+	}
+
+	if (viper.GetString("server.tls_cert") == "") != (viper.GetString("server.tls_key") == "") {
+		validationErrors = append(validationErrors, "Both tls_cert and tls_key should be set to enable TLS")
+	}
+
 	if !viper.IsSet("logs.loglevel") || viper.GetString("logs.loglevel") == "" {
 		validationErrors = append(validationErrors, "Log level is required")
 	}
 
+	if viper.GetInt("logs.debug_lines_per_second") < 0 {
+		validationErrors = append(validationErrors, "Debug lines per second should be a non-negative integer")
+	}
+
+	if format := viper.GetString("logs.log_format"); format != "console" && format != "json" {
+		validationErrors = append(validationErrors, "Log format should be either console or json")
+	}
+
 	if !viper.IsSet("logs.logtofile") {
 		validationErrors = append(validationErrors, "Log to file flag is required")
 	}
@@ -246,8 +1158,22 @@ func ValidateConfig() error {
 	}
 
 	if len(validationErrors) > 0 {
-		return errors.New(strings.Join(validationErrors, "; "))
+		return ValidationErrors(validationErrors)
 	}
 
 	return nil
 }
+
+// ValidationErrors lists every problem ValidateConfig found.
+type ValidationErrors []string
+
+func (e ValidationErrors) Error() string {
+	return strings.Join(e, "; ")
+}
+
+// CheckConfig loads the config the same way InitConfig does, without watching the file for changes,
+// and runs the validation the server runs at startup.
+func CheckConfig(configPath string) error {
+	setupViper(determineConfigFile(configPath))
+	return ValidateConfig()
+}
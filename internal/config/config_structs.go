@@ -1,28 +1,70 @@
 package config
 
-import "github.com/inhies/go-bytesize"
+import (
+	"regexp"
+
+	"github.com/inhies/go-bytesize"
+)
 
 var config Config
 
 type Config struct {
-	IndexerKeys   IndexerKeys   `mapstructure:"indexer_keys"`
-	Authorization Authorization `mapstructure:"authorization"`
-	UserIDs       UserIDs       `mapstructure:"userid"`
-	Ratio         Ratio         `mapstructure:"ratio"`
-	SizeCheck     SizeCheck     `mapstructure:"sizecheck"`
-	ParsedSizes   ParsedSizeCheck
-	Uploaders     Uploaders    `mapstructure:"uploaders"`
-	RecordLabels  RecordLabels `mapstructure:"record_labels"`
-	Logs          Logs         `mapstructure:"logs"`
+	IndexerKeys       IndexerKeys   `mapstructure:"indexer_keys"`
+	Authorization     Authorization `mapstructure:"authorization"`
+	UserIDs           UserIDs       `mapstructure:"userid"`
+	Ratio             Ratio         `mapstructure:"ratio"`
+	SizeCheck         SizeCheck     `mapstructure:"sizecheck"`
+	ParsedSizes       ParsedSizeCheck
+	Uploaders         Uploaders    `mapstructure:"uploaders"`
+	RecordLabels      RecordLabels `mapstructure:"record_labels"`
+	LogScore          LogScore     `mapstructure:"log_score"`
+	Freeleech         Freeleech    `mapstructure:"freeleech"`
+	Year              Year         `mapstructure:"year"`
+	Format            Format       `mapstructure:"format"`
+	Media             Media        `mapstructure:"media"`
+	Seeders           Seeders      `mapstructure:"seeders"`
+	Leechers          Leechers     `mapstructure:"leechers"`
+	Snatched          Snatched     `mapstructure:"snatched"`
+	ReleaseName       ReleaseName  `mapstructure:"release_name"`
+	ParsedReleaseName ParsedReleaseName
+	Remaster          Remaster `mapstructure:"remaster"`
+	ParsedRemaster    ParsedRemaster
+	Scene             Scene                             `mapstructure:"scene"`
+	Trumpable         Trumpable                         `mapstructure:"trumpable"`
+	Featured          Featured                          `mapstructure:"featured"`
+	Catalogue         Catalogue                         `mapstructure:"catalogue"`
+	ReleaseTypes      ReleaseTypes                      `mapstructure:"release_types"`
+	Tags              Tags                              `mapstructure:"tags"`
+	Age               Age                               `mapstructure:"age"`
+	RuleGroups        map[string]map[string]interface{} `mapstructure:"rule_groups"` // Named sets of payload filters, a release passing any one group is approved
+	Routes            map[string]map[string]interface{} `mapstructure:"routes"`      // Extra webhook paths, served at /<name> with their own payload filters
+	Artists           Artists                           `mapstructure:"artists"`
+	Audio             Audio                             `mapstructure:"audio"`
+	GroupIDs          GroupIDs                          `mapstructure:"group_ids"`
+	BestInGroup       BestInGroup                       `mapstructure:"best_in_group"`
+	Files             Files                             `mapstructure:"files"`
+	Scoring           Scoring                           `mapstructure:"scoring"`
+	Indexers          map[string]Indexer                `mapstructure:"indexers"`
+	HTTPClient        HTTPClient                        `mapstructure:"http_client"`
+	Cache             Cache                             `mapstructure:"cache"`
+	Server            Server                            `mapstructure:"server"`
+	Logs              Logs                              `mapstructure:"logs"`
 }
 
 type Authorization struct {
-	APIToken string `mapstructure:"api_token"`
+	APIToken     string `mapstructure:"api_token"`
+	HMACSecret   string `mapstructure:"hmac_secret"`   // Shared secret for X-Signature verification, empty skips the check
+	WebhookToken string `mapstructure:"webhook_token"` // Token expected in an Authorization: Bearer header, empty skips the check
+
+	AllowedIPs     []string `mapstructure:"allowed_ips"`     // IPs and CIDRs allowed to call the webhook, empty allows any
+	TrustedProxies []string `mapstructure:"trusted_proxies"` // Proxies whose X-Forwarded-For header is followed to find the client
 }
 
 type IndexerKeys struct {
 	REDKey string `mapstructure:"red_apikey"`
 	OPSKey string `mapstructure:"ops_apikey"`
+
+	VerifyOnStartup bool `mapstructure:"verify_keys_on_startup"` // Make an index call with every API key on boot and exit if one is rejected
 }
 
 type UserIDs struct {
@@ -31,7 +73,8 @@ type UserIDs struct {
 }
 
 type Ratio struct {
-	MinRatio float64 `mapstructure:"minratio"`
+	MinRatio       float64 `mapstructure:"minratio"`
+	MinRatioBuffer string  `mapstructure:"min_ratio_buffer"` // Buffer that must be left after the download, e.g. "10GB"
 }
 
 type SizeCheck struct {
@@ -47,18 +90,230 @@ type ParsedSizeCheck struct {
 type Uploaders struct {
 	Uploaders string `mapstructure:"uploaders"`
 	Mode      string `mapstructure:"mode"`
+	Allow     string `mapstructure:"allow"`
+	Block     string `mapstructure:"block"`
 }
 
 type RecordLabels struct {
-	RecordLabels string `mapstructure:"record_labels"`
+	RecordLabels        string `mapstructure:"record_labels"`
+	BlockedRecordLabels string `mapstructure:"blocked_record_labels"`
+}
+
+type LogScore struct {
+	MinLogScore int  `mapstructure:"min_log_score"`
+	Strict      bool `mapstructure:"strict"`
+	RequireLog  bool `mapstructure:"require_log"`
+	RequireCue  bool `mapstructure:"require_cue"`
+}
+
+type Freeleech struct {
+	FreeleechOnly bool `mapstructure:"freeleech_only"`
+	SkipFreeleech bool `mapstructure:"skip_freeleech"`
+}
+
+type Year struct {
+	MinYear      int  `mapstructure:"min_year"`
+	MaxYear      int  `mapstructure:"max_year"`
+	AllowUnknown bool `mapstructure:"allow_unknown"`
+}
+
+type Format struct {
+	Formats            string `mapstructure:"formats"`
+	Encodings          string `mapstructure:"encodings"`
+	EncodingPreference string `mapstructure:"encoding_preference"` // Encodings from most to least preferred, the best one in the group is taken
+}
+
+type Media struct {
+	Media string `mapstructure:"media"`
+}
+
+type Seeders struct {
+	MinSeeders int `mapstructure:"min_seeders"`
+}
+
+type Leechers struct {
+	MinLeechers int `mapstructure:"min_leechers"`
+}
+
+type Snatched struct {
+	MinSnatched int `mapstructure:"min_snatched"`
+}
+
+type ReleaseName struct {
+	MustMatch    []string `mapstructure:"must_match"`     // Regexes the release name must all match
+	MustNotMatch []string `mapstructure:"must_not_match"` // Regexes the release name must not match
+}
+
+type ParsedReleaseName struct {
+	MustMatch    []*regexp.Regexp
+	MustNotMatch []*regexp.Regexp
+}
+
+type Remaster struct {
+	TitleMustMatch    []string `mapstructure:"title_must_match"`     // Regexes the remaster title must all match
+	TitleMustNotMatch []string `mapstructure:"title_must_not_match"` // Regexes the remaster title must not match
+	Years             []int    `mapstructure:"years"`                // Edition years to allow, empty allows any
+}
+
+type ParsedRemaster struct {
+	TitleMustMatch    []*regexp.Regexp
+	TitleMustNotMatch []*regexp.Regexp
+}
+
+type Scene struct {
+	SceneOnly bool `mapstructure:"scene_only"`
+	SkipScene bool `mapstructure:"skip_scene"`
+}
+
+type Trumpable struct {
+	SkipTrumpable bool `mapstructure:"skip_trumpable"` // Reject torrents the tracker flags as reported or trumpable
+}
+
+type Featured struct {
+	RequireFeatured bool `mapstructure:"require_featured"` // Only allow releases the indexer flags as featured
+	SkipFeatured    bool `mapstructure:"skip_featured"`    // Reject releases the indexer flags as featured
+}
+
+// FeaturedFlags are the flags featured_flag can name, "none" for indexers without one.
+var FeaturedFlags = []string{"vanity_house", "none"}
+
+type Catalogue struct {
+	CatalogueNumbers string `mapstructure:"catalogue_numbers"`
+}
+
+type ReleaseTypes struct {
+	ReleaseTypes []string `mapstructure:"release_types"`
+}
+
+type Tags struct {
+	RequireAny []string `mapstructure:"require_any"` // At least one of these tags must be present
+	Exclude    []string `mapstructure:"exclude"`     // None of these tags may be present
+}
+
+type Age struct {
+	MaxAge string `mapstructure:"max_age"` // Oldest upload accepted, as a duration like "1h", empty or "0" disables
+}
+
+type Artists struct {
+	RequireArtist []string `mapstructure:"require_artist"` // At least one of these artists must be credited on the torrent group
+	Roles         []string `mapstructure:"roles"`          // Roles the artists must be credited in, empty allows any role
+}
+
+// ArtistRoles are the roles an artist can be credited in on a torrent group.
+var ArtistRoles = []string{"main", "guest", "composer", "conductor", "dj", "remixer", "producer"}
+
+type Audio struct {
+	MinBitDepth        int       `mapstructure:"min_bit_depth"`        // Lowest bit depth allowed, e.g. 24
+	AllowedSampleRates []float64 `mapstructure:"allowed_sample_rates"` // Sample rates in kHz to allow, empty allows any
+	AllowUnknown       bool      `mapstructure:"allow_unknown"`        // Allow torrents whose bit depth or sample rate is unknown
+}
+
+type GroupIDs struct {
+	Allow []int `mapstructure:"allow"` // Only torrent groups with one of these IDs are allowed, empty allows any
+	Block []int `mapstructure:"block"` // Torrent groups with these IDs are always rejected
+}
+
+type BestInGroup struct {
+	Enabled    bool     `mapstructure:"enabled"`    // Reject a torrent when its group has one of a preferred format and encoding
+	Preference []string `mapstructure:"preference"` // Formats and encodings from best to worst, e.g. "FLAC 24bit Lossless"
+}
+
+type Files struct {
+	MinFiles int `mapstructure:"min_files"` // Fewest files a torrent may contain, 0 means no lower bound
+	MaxFiles int `mapstructure:"max_files"` // Most files a torrent may contain, 0 means no upper bound
+}
+
+type Scoring struct {
+	MinScore int         `mapstructure:"min_score"` // Lowest total score a torrent needs, only used once there are rules
+	Rules    []ScoreRule `mapstructure:"rules"`     // Points given for torrent attributes, scoring is off without any
+}
+
+// ScoreRule gives points to torrents whose field matches, or points for every per of a count field.
+type ScoreRule struct {
+	Field  string `mapstructure:"field"`  // One of ScoreTextFields, ScoreFlagFields or ScoreCountFields
+	Value  string `mapstructure:"value"`  // Value a text field must have, matched case-insensitively
+	Per    int    `mapstructure:"per"`    // Count of a count field the points are given for, e.g. 100 seeders
+	Points int    `mapstructure:"points"` // Points added to the score, negative to penalize
+}
+
+// the torrent attributes scoring rules can name: text fields match a value, flag fields match when set
+// and count fields give points per count.
+var (
+	ScoreTextFields  = []string{"format", "encoding", "media", "uploader", "record_label", "release_type", "tag"}
+	ScoreFlagFields  = []string{"scene", "has_log", "has_cue", "freeleech", "reported", "trumpable", "featured"}
+	ScoreCountFields = []string{"seeders", "leechers", "snatched", "log_score"}
+)
+
+type Indexer struct {
+	Timeout     int    `mapstructure:"timeout"`      // Seconds to wait for an API response
+	RateLimit   int    `mapstructure:"rate_limit"`   // API requests allowed per 10 seconds
+	Burst       int    `mapstructure:"burst"`        // Requests that may be sent back to back
+	SmoothBurst bool   `mapstructure:"smooth_burst"` // Spaces requests evenly instead of letting an idle limiter burst
+	APIBase     string `mapstructure:"api_base"`     // Overrides the built-in API endpoint, e.g. for a staging mirror
+	APIKey      string `mapstructure:"api_key"`      // API key for custom profiles, redacted and ops use indexer_keys
+	UserID      int    `mapstructure:"user_id"`      // User ID for the ratio check on custom profiles, redacted and ops use userid
+	AuthHeader  string `mapstructure:"auth_header"`  // Header the API key is sent in, defaults to Authorization
+	AuthFormat  string `mapstructure:"auth_format"`  // Value of the auth header, {key} is replaced by the API key
+
+	BreakerThreshold int `mapstructure:"breaker_threshold"` // Consecutive failures before requests are short-circuited, 0 disables
+	BreakerCooldown  int `mapstructure:"breaker_cooldown"`  // Seconds to short-circuit requests before probing again
+
+	AdaptiveLatency      int `mapstructure:"adaptive_latency"`        // Target p95 API latency in milliseconds, slower responses lower the rate, 0 disables
+	AdaptiveMinRateLimit int `mapstructure:"adaptive_min_rate_limit"` // Lowest requests per 10 seconds the adaptive rate goes down to
+
+	FallbackIndexer string `mapstructure:"fallback_indexer"` // Indexer to check cross-listed torrents against while this one is unavailable
+	FeaturedFlag    string `mapstructure:"featured_flag"`    // Flag marking featured releases on this indexer, see FeaturedFlags, empty uses vanity_house
+}
+
+type HTTPClient struct {
+	MaxAttempts           int    `mapstructure:"max_attempts"`            // Attempts per API request, including the first
+	RetryDelay            int    `mapstructure:"retry_delay"`             // Base backoff between attempts in milliseconds
+	MaxIdleConns          int    `mapstructure:"max_idle_conns"`          // Idle connections kept across all indexers
+	MaxIdleConnsPerHost   int    `mapstructure:"max_idle_conns_per_host"` // Idle connections kept per indexer
+	IdleConnTimeout       int    `mapstructure:"idle_conn_timeout"`       // Seconds an idle connection is kept open
+	MaxBodySize           int    `mapstructure:"max_body_size"`           // Largest API response body accepted, in bytes
+	MaxConcurrentRequests int    `mapstructure:"max_concurrent_requests"` // API requests in flight at once across all indexers, 0 is unlimited
+	HTTP2                 bool   `mapstructure:"http2"`                   // Negotiate HTTP/2 with trackers that support it, false forces HTTP/1.1
+	UserAgent             string `mapstructure:"user_agent"`              // User-Agent sent to trackers, empty uses redactedhook/<version>
+}
+
+type Cache struct {
+	TTL         int            `mapstructure:"ttl"`          // Seconds to keep API responses cached, 0 disables the cache
+	ActionTTL   map[string]int `mapstructure:"action_ttl"`   // Per action overrides of TTL, e.g. longer for artist and collage
+	NegativeTTL int            `mapstructure:"negative_ttl"` // Seconds to keep failures like a bad ID cached, 0 disables it
+	TTLJitter   int            `mapstructure:"ttl_jitter"`   // Percentage the TTL is randomly varied by, so entries do not all expire at once
+	MaxEntries  int            `mapstructure:"max_entries"`  // Responses kept before the least recently used is evicted
+	PersistPath string         `mapstructure:"persist_path"` // File the cache is saved to and warmed from, empty keeps it in memory
+
+	ResolveHashes bool `mapstructure:"resolve_hashes"` // Cache torrents looked up by infohash under their ID, so hash and ID lookups share entries
+}
+
+type Server struct {
+	Address          string `mapstructure:"address"`           // Address to listen on
+	Port             string `mapstructure:"port"`              // Port to listen on
+	ListenAddr       string `mapstructure:"listen_addr"`       // host:port or unix:/path/to/socket, replaces Address and Port when set
+	ShutdownTimeout  int    `mapstructure:"shutdown_timeout"`  // Seconds to wait for in-flight checks on shutdown
+	DryRun           bool   `mapstructure:"dry_run"`           // Log filter decisions but always approve
+	OnAPIError       string `mapstructure:"on_api_error"`      // "reject" or "approve" when the tracker cannot be reached
+	RejectStatus     int    `mapstructure:"reject_status"`     // Status code sent for every rejection, 0 keeps the status of the failed check
+	IncludeEvaluated bool   `mapstructure:"include_evaluated"` // Add the checks that passed and the torrent attributes to approvals
+	ClientRateLimit  int    `mapstructure:"client_rate_limit"` // Requests per minute allowed from one client address, 0 disables the limit
+	GlobalRateLimit  int    `mapstructure:"global_rate_limit"` // Requests per minute allowed from all clients together, 0 disables the limit
+	DebugHistory     int    `mapstructure:"debug_history"`     // Webhook requests kept in memory for /debug/requests, 0 disables it
+	TLSCert          string `mapstructure:"tls_cert"`          // Certificate file to serve HTTPS with
+	TLSKey           string `mapstructure:"tls_key"`           // Private key file for TLSCert
 }
 
 type Logs struct {
-	LogLevel    string `mapstructure:"loglevel"`
-	LogToFile   bool   `mapstructure:"logtofile"`
-	LogFilePath string `mapstructure:"logfilepath"`
-	MaxSize     int    `mapstructure:"maxsize"`    // Max file size in MB
-	MaxBackups  int    `mapstructure:"maxbackups"` // Max number of old log files to keep
-	MaxAge      int    `mapstructure:"maxage"`     // Max age in days to keep a log file
-	Compress    bool   `mapstructure:"compress"`   // Whether to compress old log files
+	LogLevel            string `mapstructure:"loglevel"`
+	LogFormat           string `mapstructure:"log_format"`             // console or json
+	LogAPIResponses     bool   `mapstructure:"log_api_responses"`      // Log every tracker response at trace level, secrets redacted
+	DebugLinesPerSecond int    `mapstructure:"debug_lines_per_second"` // Trace and debug lines logged per second at most, 0 logs all of them
+	LogToFile           bool   `mapstructure:"logtofile"`
+	LogToConsole        bool   `mapstructure:"logtoconsole"` // Mirror the logs to stderr while logging to a file
+	LogFilePath         string `mapstructure:"logfilepath"`
+	MaxSize             int    `mapstructure:"maxsize"`    // Max file size in MB
+	MaxBackups          int    `mapstructure:"maxbackups"` // Max number of old log files to keep
+	MaxAge              int    `mapstructure:"maxage"`     // Max age in days to keep a log file
+	Compress            bool   `mapstructure:"compress"`   // Whether to compress old log files
 }
@@ -3,18 +3,35 @@ package config
 import (
 	"io"
 	"os"
+	"time"
 
 	"github.com/natefinch/lumberjack"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
+// the rotating log file currently written to, closed when the logger is configured again.
+var logFile *lumberjack.Logger
+
 func configureLogger() {
 	var writers []io.Writer
 
-	// Always log to console
-	consoleWriter := zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: "2006-01-02 15:04:05"}
-	writers = append(writers, consoleWriter)
+	// Log to console, either human-readable or as one JSON object per line. it can only be turned off
+	// while logging to a file, so the logs always end up somewhere
+	if config.Logs.LogToConsole || !config.Logs.LogToFile {
+		if config.Logs.LogFormat == "json" {
+			writers = append(writers, os.Stderr)
+		} else {
+			consoleWriter := zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: "2006-01-02 15:04:05"}
+			writers = append(writers, consoleWriter)
+		}
+	}
+
+	// the previous file is closed even when file logging was turned off, so it isn't left open
+	if logFile != nil {
+		logFile.Close()
+		logFile = nil
+	}
 
 	// If logtofile is true, also log to file
 	if config.Logs.LogToFile {
@@ -30,11 +47,18 @@ func configureLogger() {
 			Compress:   config.Logs.Compress,   // compress rolling files
 		}
 		writers = append(writers, fileWriter)
+		logFile = fileWriter
 	}
 
 	// Combine all writers
 	multiWriter := zerolog.MultiLevelWriter(writers...)
 	log.Logger = zerolog.New(multiWriter).With().Timestamp().Logger()
+	if linesPerSecond := config.Logs.DebugLinesPerSecond; linesPerSecond > 0 {
+		// trace and debug lines share one budget, info and above are never dropped
+		sampler := &zerolog.BurstSampler{Burst: uint32(linesPerSecond), Period: time.Second}
+		log.Logger = log.Logger.Sample(zerolog.LevelSampler{TraceSampler: sampler, DebugSampler: sampler})
+	}
+	zerolog.DefaultContextLogger = &log.Logger // used by log.Ctx when a context carries no request logger
 
 	// Set the log level
 	setLogLevel(config.Logs.LogLevel)
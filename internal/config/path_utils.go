@@ -14,6 +14,11 @@ func isRunningInDocker() bool {
 	return false
 }
 
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
 func determineConfigFile(configPath string) string {
 	if configPath != "" {
 		return configPath
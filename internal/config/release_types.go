@@ -0,0 +1,44 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// releaseTypes maps the gazelle release type codes to the names shown on the trackers.
+var releaseTypes = map[int]string{
+	1:  "Album",
+	3:  "Soundtrack",
+	5:  "EP",
+	6:  "Anthology",
+	7:  "Compilation",
+	9:  "Single",
+	11: "Live album",
+	13: "Remix",
+	14: "Bootleg",
+	15: "Interview",
+	16: "Mixtape",
+	17: "Demo",
+	18: "Concert Recording",
+	19: "DJ Mix",
+	21: "Unknown",
+}
+
+// ReleaseTypeCode returns the code for a release type name, matched case-insensitively.
+func ReleaseTypeCode(name string) (int, bool) {
+	name = strings.TrimSpace(name)
+	for code, releaseType := range releaseTypes {
+		if strings.EqualFold(releaseType, name) {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// ReleaseTypeName returns the name for a release type code, or the code itself when it is unknown.
+func ReleaseTypeName(code int) string {
+	if name, ok := releaseTypes[code]; ok {
+		return name
+	}
+	return strconv.Itoa(code)
+}